@@ -4,8 +4,15 @@
 package hoverdnsapi
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // HoverAct is simply an enum type to typecheck various actions we can perform in a queue
@@ -22,6 +29,8 @@ const (
 	Update
 	// Expand is an internal state that will expand a domain to include entries
 	Expand
+	// DeleteAll deletes every record matching, where Delete refuses to pick one of several
+	DeleteAll
 )
 
 // String of course gives a string representation of the Act code
@@ -37,28 +46,206 @@ func (h HoverAct) String() string {
 		return "Update"
 	case Expand:
 		return "--Expand--"
+	case DeleteAll:
+		return "DeleteAll"
 	}
 
 	return "(error) HoverAct const extended without String() equivalent"
 }
 
+// Record types that Hover's DNS editor offers.  These are the values sent as the "type" of a
+// record, and seen in Entry.Type when a domain is expanded.
+const (
+	TypeA     = "A"
+	TypeAAAA  = "AAAA"
+	TypeCAA   = "CAA"
+	TypeCNAME = "CNAME"
+	TypeMX    = "MX"
+	TypeNS    = "NS"
+	TypeSRV   = "SRV"
+	TypeTXT   = "TXT"
+)
+
+// ValidTTLs are the TTLs, in seconds, that Hover's DNS editor offers: 5 minutes to a day.  A
+// record sent with any other TTL is refused.
+var ValidTTLs = []uint{300, 900, 3600, 14400, 43200, 86400}
+
+// hoverDefaultTTL is the TTL that Hover gives a record created without one
+const hoverDefaultTTL = 900
+
+// ValidTTL checks whether the TTL is one that Hover accepts; zero is also valid, and means that
+// no TTL is sent, leaving Hover to use its default (900 at the time of writing).
+func ValidTTL(ttl uint) bool {
+	if ttl == 0 {
+		return true
+	}
+	for _, v := range ValidTTLs {
+		if ttl == v {
+			return true
+		}
+	}
+	return false
+}
+
+// recordTypes is the set of record types we know Hover to accept
+var recordTypes = map[string]bool{
+	TypeA:     true,
+	TypeAAAA:  true,
+	TypeCAA:   true,
+	TypeCNAME: true,
+	TypeMX:    true,
+	TypeNS:    true,
+	TypeSRV:   true,
+	TypeTXT:   true,
+}
+
+// ValidRecordType checks whether the given record type (case-insensitive) is one that Hover
+// accepts
+func ValidRecordType(rrtype string) bool {
+	return recordTypes[strings.ToUpper(rrtype)]
+}
+
 // Action is a single action (Add, Update, Delete) to complete in a DoActions() call
 type Action struct {
-	action HoverAct
-	fqdn   string
-	domain string
-	value  string
-	ttl    uint
+	action   HoverAct
+	fqdn     string
+	domain   string
+	rrtype   string // record type: A, MX, TXT, etc; blank on a Delete matches any type
+	value    string // blank on a Delete matches any value
+	ttl      uint   // seconds, one of ValidTTLs; zero leaves Hover to use its default
+	priority uint   // MX and SRV priority
+	weight   uint   // SRV weight
+	port     uint   // SRV port
+	id       string // entry to act upon, as found by Diff; blank finds it by FQDN, type, and value
 }
 
 func (a Action) String() string {
-	return fmt.Sprintf("{action:%s domain:%s fqdn:%s, value:%s ttl:%d}", a.action, a.domain, a.fqdn, a.value, a.ttl)
+	if a.id != "" {
+		return fmt.Sprintf("{action:%s domain:%s fqdn:%s, type:%s value:%s ttl:%d id:%s}", a.action, a.domain, a.fqdn, a.rrtype, a.Content(), a.ttl, a.id)
+	}
+	return fmt.Sprintf("{action:%s domain:%s fqdn:%s, type:%s value:%s ttl:%d}", a.action, a.domain, a.fqdn, a.rrtype, a.Content(), a.ttl)
 }
 
 // NewAction returns a newly-created Action as a way to allow external access to create actions
-// during test runs using cmd/hoverdns app
+// during test runs using cmd/hoverdns app.  The record is assumed to be a TXT record, which is
+// the common case for ACME challenges; use NewRecordAction for other types.
 func NewAction(action HoverAct, fqdn, domain, value string, ttl uint) Action {
-	return Action{action: action, fqdn: fqdn, domain: domain, value: value, ttl: ttl}
+	return NewRecordAction(action, fqdn, domain, TypeTXT, value, ttl)
+}
+
+// NewRecordAction returns a newly-created Action for a record of the given type (A, AAAA, CNAME,
+// MX, SRV, CAA, NS, TXT).  Type-specific values such as the MX priority or SRV weight and port
+// can be layered on using WithPriority, WithWeight, and WithPort.
+func NewRecordAction(action HoverAct, fqdn, domain, rrtype, value string, ttl uint) Action {
+	return Action{action: action, fqdn: fqdn, domain: domain, rrtype: strings.ToUpper(rrtype), value: value, ttl: ttl}
+}
+
+// WithPriority returns a copy of the Action with the given priority, used by MX and SRV records
+func (a Action) WithPriority(priority uint) Action {
+	a.priority = priority
+	return a
+}
+
+// WithWeight returns a copy of the Action with the given weight, used by SRV records
+func (a Action) WithWeight(weight uint) Action {
+	a.weight = weight
+	return a
+}
+
+// WithPort returns a copy of the Action with the given port, used by SRV records
+func (a Action) WithPort(port uint) Action {
+	a.port = port
+	return a
+}
+
+// WithEntryID sets the Hover ID of the entry that a Delete or Update acts upon, rather than
+// finding it by FQDN, type, and value; an entry of that ID that's since gone is ErrRecordNotFound
+// for an Update, and already done for a Delete.
+func (a Action) WithEntryID(id string) Action {
+	a.id = id
+	return a
+}
+
+// checkTTL refuses a TTL that Hover wouldn't accept, before anything is sent
+func (a Action) checkTTL() error {
+	if !ValidTTL(a.ttl) {
+		return fmt.Errorf("hover: TTL %d of %s %s isn't one of %v: %w", a.ttl, a.rrtype, a.fqdn, ValidTTLs, ErrUnactionable)
+	}
+	return nil
+}
+
+// withTTL adds the TTL to the values of a request, if one is set
+func (a Action) withTTL(values url.Values) url.Values {
+	if a.ttl != 0 {
+		values.Set("ttl", strconv.FormatUint(uint64(a.ttl), 10))
+	}
+	return values
+}
+
+// matching returns the entries of the domain that the Action acts upon: those of its FQDN, and
+// of its type and value if given.  The value matches either the entry's content as a whole, or
+// as Content() would format it (ie with the MX priority).
+func (a Action) matching(d Domain) []Entry {
+	matches := make([]Entry, 0)
+	if a.id != "" {
+		for _, e := range d.Entries {
+			if e.ID == a.id {
+				matches = append(matches, e)
+			}
+		}
+		return matches
+	}
+
+	for _, e := range d.FindEntries(a.fqdn, a.rrtype, "") {
+		if a.value == "" || e.Content == a.value || e.Content == a.Content() {
+			matches = append(matches, e)
+		}
+	}
+	return matches
+}
+
+// Act returns what the Action does: Add, Delete, and so on
+func (a Action) Act() HoverAct {
+	return a.action
+}
+
+// FQDN returns the name of the record that the Action acts upon
+func (a Action) FQDN() string {
+	return a.fqdn
+}
+
+// Type returns the record type that the Action acts upon
+func (a Action) Type() string {
+	return a.rrtype
+}
+
+// TTL returns the TTL given to the record, or zero to leave it to Hover
+func (a Action) TTL() uint {
+	return a.ttl
+}
+
+// EntryID returns the ID of the entry that the Action acts upon, if given by WithEntryID
+func (a Action) EntryID() string {
+	return a.id
+}
+
+// Content renders the value of the record as Hover stores it in Entry.Content: for MX, the
+// priority is prefixed ("10 mx.example.com"); for SRV, the priority, weight, and port are
+// prefixed ("10 5 5060 sip.example.com").  If the value already contains spaces, it's assumed to
+// be pre-formatted and is used verbatim.
+func (a Action) Content() string {
+	if strings.Contains(a.value, " ") {
+		return a.value
+	}
+
+	switch a.rrtype {
+	case TypeMX:
+		return fmt.Sprintf("%d %s", a.priority, a.value)
+	case TypeSRV:
+		return fmt.Sprintf("%d %d %d %s", a.priority, a.weight, a.port, a.value)
+	}
+
+	return a.value
 }
 
 // DoActions is a way to burn down an accumulated list of actions.  Mostly, this stack will be one
@@ -66,12 +253,30 @@ func NewAction(action HoverAct, fqdn, domain, value string, ttl uint) Action {
 // needed, or a detailed DNS list if needed, in a sort of lazy-evaluation logic that avoid these
 // actions if not needed.
 func (c *Client) DoActions(actions ...Action) (err error) {
+	return c.DoActionsContext(context.Background(), actions...)
+}
+
+// DoActionsContext is DoActions bound to a context: cancelling the context abandons the
+// remaining actions, and the context is threaded through login, domain fetch, expansion, and
+// each mutation.
+//
+// Every action is attempted even if an earlier one fails; if any fail, the error returned is an
+// *ActionsError describing exactly which.
+func (c *Client) DoActionsContext(ctx context.Context, actions ...Action) (err error) {
+	_, err = c.DoActionsResults(ctx, actions...)
+	return err
+}
+
+// DoActionsResults is DoActionsContext that also returns the outcome of each of the given
+// actions, in order, such as the strategy used for an Update.
+func (c *Client) DoActionsResults(ctx context.Context, actions ...Action) ([]ActionResult, error) {
 	var expansion = map[HoverAct]bool{
-		Error:  false,
-		Add:    false,
-		Delete: true,
-		Update: true,
-		Expand: false, // of course
+		Error:     false,
+		Add:       false,
+		Delete:    true,
+		Update:    true,
+		Expand:    false, // of course
+		DeleteAll: true,
 	}
 
 	// precondition the steps: prepend expansion where needed
@@ -85,21 +290,15 @@ func (c *Client) DoActions(actions ...Action) (err error) {
 			c.log.Printf("current action (%d), %+v no pre-pending", n, a)
 		}
 
-		if a.action == Update { // I just could NOT get update to work, so delete and re-add.  Sorry.
-			// NOTE: deleting and re-adding in dumbest way possible to incentivize me to fix it ;)
-			newActions = append(newActions, Action{action: Delete, fqdn: a.fqdn, domain: a.domain})
-			newActions = append(newActions, Action{action: Add, fqdn: a.fqdn, domain: a.domain, value: a.value, ttl: a.ttl})
-		} else {
-			newActions = append(newActions, a)
-		}
+		newActions = append(newActions, a)
 	}
 	for n, a := range newActions {
 		c.log.Printf("resulting actions (%d), %+v", n, a)
 	}
 
-	if len(c.domains.Domains) < 1 { // todo make an action in newActions
-		if err = c.FillDomains(); err != nil {
-			return err
+	if !c.domainsFresh() || c.missingDomain(actions) { // todo make an action in newActions
+		if err := c.FillDomainsContext(ctx); err != nil {
+			return nil, err
 		}
 	}
 
@@ -107,46 +306,339 @@ func (c *Client) DoActions(actions ...Action) (err error) {
 	//     action:2 fqdn:_acme-challenge.domain.com domain: domain.com
 	//     value:xzLAGicQ1PtUwmXLyCsagNI7O4m_Zsn8mcVREy7QrfY ttl:3600
 	// }
+	results := make([]ActionResult, 0, len(actions))
+	expandErr := make(map[string]error) // failed expansions fail the actions depending on them
+	failed := false
 	for actnum, a := range newActions {
-		if domain, ok := c.GetDomainByName(a.domain); ok {
-			fmt.Printf("Action Stack pre (%02d): [%s]\n", actnum, a)
-			switch a.action {
-			case Error:
-				return fmt.Errorf("Error: unset action code: %+v", a)
-			case Add:
-				if resp, err := c.HTTPClient.PostForm(APIURLDNS(domain.ID), url.Values{
-					"name":    {a.fqdn},
-					"type":    {"TXT"},
-					"content": {a.value},
-				}); err != nil {
-					fmt.Printf("hover: Info: posting threw: [%+v]\n", err)
-				} else {
-					resp.Body.Close()
-					domain.Entries = make([]Entry, 0) // discard to force refresh on demand
-				}
-			case Delete:
-				if len(domain.Entries) < 1 {
-					c.log.Printf(`NOTE: entries for domain "%s" are empty`, domain.DomainName)
-				} else if e, ok := domain.GetEntryByFQDN(a.fqdn); !ok {
-					c.log.Printf(`NOTE: FQDN "%s" in domain "%s" not found`, a.fqdn, domain.DomainName)
-				} else if err := c.HTTPDelete(fmt.Sprintf("%s/%s", APIURLDNS(domain.ID), e.ID)); err != nil {
-					c.log.Printf("hover: Info: deleting threw: [%+v]\n", err)
-				}
-			// As above, I just couldn't get Update to work, so I've doing a delete/add swapped above.  Therefore Update here should just fall-through
-			//case Update:
-
-			case Expand:
-				if len(domain.Entries) < 1 {
-					if err := c.GetDomainEntries(a.domain); err != nil {
-						return fmt.Errorf("Domain %s not extended: %v", a.domain, err)
-					}
+		c.log.Printf("Action Stack pre (%02d): [%s]", actnum, a)
+		if a.action == Expand {
+			expandErr[a.domain] = c.expand(ctx, a.domain)
+			continue
+		}
+
+		result := ActionResult{Action: a}
+		result.Strategy, result.Err = c.doAction(ctx, a, expandErr[a.domain])
+		if result.Err != nil {
+			c.log.Printf("hover: Info: action (%02d) [%s] failed: %v", actnum, a, result.Err)
+			failed = true
+		}
+		results = append(results, result)
+		c.log.Printf("Action Stack post (%02d): [%s]", actnum, a)
+	}
+
+	if failed {
+		return results, &ActionsError{Results: results}
+	}
+	return results, nil
+}
+
+// ActionResult records the outcome of a single Action processed by DoActions
+type ActionResult struct {
+	Action   Action
+	Strategy UpdateStrategy // how an Update was carried out; UpdateNone for other actions
+	Err      error          // nil if the action succeeded
+}
+
+// missingDomain reports whether any of the actions is upon a domain not in the cached list, such
+// as one added since the list was read; the list is then read again, once, before giving up on it
+func (c *Client) missingDomain(actions []Action) bool {
+	for _, a := range actions {
+		if _, ok := c.GetDomainByName(a.domain); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// expand fills the entries of the domain if they're not already known, or are stale
+func (c *Client) expand(ctx context.Context, domainname string) error {
+	if _, ok := c.GetDomainByName(domainname); !ok || c.entriesFresh(domainname) {
+		return nil // missing domain is reported by the action itself
+	}
+	if err := c.GetDomainEntriesContext(ctx, domainname); err != nil {
+		return fmt.Errorf("Domain %s not extended: %w", domainname, err)
+	}
+	return nil
+}
+
+// doAction carries out a single Add, Delete, or Update.  expanded is the result of expanding the
+// domain's entries, which Delete and Update depend on.
+func (c *Client) doAction(ctx context.Context, a Action, expanded error) (UpdateStrategy, error) {
+	if err := ctx.Err(); err != nil {
+		return UpdateNone, err
+	}
+
+	domain, ok := c.GetDomainByName(a.domain)
+	if !ok {
+		c.log.Printf("domain %s not found", a.domain)
+		return UpdateNone, fmt.Errorf("Domain %s not found in domains: %w", a.domain, ErrDomainNotFound)
+	}
+	defer c.InvalidateDomain(domain.DomainName) // even a failure may have changed something
+
+	switch a.action {
+	case Add:
+		if !ValidRecordType(a.rrtype) {
+			return UpdateNone, fmt.Errorf("Error: unsupported record type %q: %+v", a.rrtype, a)
+		}
+		_, err := c.addEntry(ctx, domain, a)
+		return UpdateNone, err
+	case Delete, DeleteAll:
+		if expanded != nil {
+			return UpdateNone, expanded
+		}
+		matches := a.matching(*domain)
+		switch {
+		case len(domain.Entries) < 1:
+			c.log.Printf(`NOTE: entries for domain "%s" are empty`, domain.DomainName)
+		case len(matches) < 1:
+			c.log.Printf(`NOTE: FQDN "%s" (type "%s") in domain "%s" not found`, a.fqdn, a.rrtype, domain.DomainName)
+		case len(matches) > 1 && a.action == Delete:
+			return UpdateNone, fmt.Errorf("hover: %d records match %s %s in %s; give the value, or use DeleteAll: %w", len(matches), a.rrtype, a.fqdn, domain.DomainName, ErrAmbiguous)
+		}
+		for _, e := range matches { // refuse all before deleting any
+			if err := c.mayDelete(ctx, domain.DomainName, e); err != nil {
+				return UpdateNone, err
+			}
+		}
+		for _, e := range matches {
+			if err := c.removeEntry(ctx, domain, e); err != nil {
+				return UpdateNone, err
+			}
+		}
+		return UpdateNone, nil
+	case Update:
+		if expanded != nil {
+			return UpdateNone, expanded
+		}
+		if !ValidRecordType(a.rrtype) {
+			return UpdateNone, fmt.Errorf("Error: unsupported record type %q: %+v", a.rrtype, a)
+		}
+		strategy, err := c.updateEntry(ctx, domain, a)
+		if err == nil {
+			c.log.Printf(`updated FQDN "%s" in domain "%s" using strategy %s`, a.fqdn, domain.DomainName, strategy)
+		}
+		return strategy, err
+	}
+
+	return UpdateNone, fmt.Errorf("Error: unset action code: %+v", a)
+}
+
+// UpdateStrategy reports how an Update action was carried out
+type UpdateStrategy int
+
+const (
+	// UpdateNone is the zero-value: no update was attempted
+	UpdateNone UpdateStrategy = iota
+	// UpdateInPlace edited the existing entry by ID, retaining its ID and CanRevert
+	UpdateInPlace
+	// UpdateReplace deleted the existing entry and added a new one because Hover refused the
+	// in-place edit; the record gets a new ID
+	UpdateReplace
+	// UpdateCreate added a new entry because no existing entry was found to update
+	UpdateCreate
+)
+
+// String of course gives a string representation of the UpdateStrategy
+func (s UpdateStrategy) String() string {
+	switch s {
+	case UpdateNone:
+		return "none"
+	case UpdateInPlace:
+		return "in-place"
+	case UpdateReplace:
+		return "delete/add"
+	case UpdateCreate:
+		return "add"
+	}
+
+	return "(error) UpdateStrategy const extended without String() equivalent"
+}
+
+// refusedUpdate lists the statuses with which Hover refuses an in-place update that a delete/add
+// can still accomplish.  422 (Unactionable) is the one seen most.
+var refusedUpdate = map[int]bool{
+	http.StatusBadRequest:          true,
+	http.StatusNotFound:            true,
+	http.StatusMethodNotAllowed:    true,
+	http.StatusUnprocessableEntity: true,
+	http.StatusNotImplemented:      true,
+}
+
+// addEntry posts a new record to the domain.
+//
+// A POST isn't idempotent: if it fails in a retryable way, the first attempt may still have
+// created the record.  Before each retry, the domain's entries are re-read, and if the record is
+// found, the add is considered done rather than duplicated.
+func (c *Client) addEntry(ctx context.Context, domain *Domain, a Action) (created Entry, err error) {
+	if err := a.checkTTL(); err != nil {
+		return Entry{}, err
+	}
+	call := apiCall{method: http.MethodPost, url: c.APIURLDNS(domain.ID), values: a.withTTL(url.Values{
+		"name":    {a.fqdn},
+		"type":    {a.rrtype},
+		"content": {a.Content()},
+	})}
+
+	for attempt := 1; ; attempt++ {
+		if created, err = c.postEntry(ctx, call); err == nil || attempt >= c.retry.MaxAttempts || !c.retry.retryableErr(ctx, err) {
+			break
+		}
+
+		var (
+			hold time.Duration
+			he   *HTTPError
+		)
+		if errors.As(err, &he) {
+			hold = he.RetryAfter
+		}
+		wait := c.backoff(c.retry, attempt, hold)
+		c.log.Printf("hover: add of %s failed (%v); checking before retrying in %s", a.fqdn, err, wait)
+		if serr := sleep(ctx, wait); serr != nil {
+			return Entry{}, serr
+		}
+		if c.GetDomainEntriesContext(ctx, domain.DomainName) == nil {
+			if refreshed, ok := c.GetDomainByName(domain.DomainName); ok {
+				if e, ok := refreshed.findEntry(a.fqdn, a.rrtype, a.Content()); ok {
+					c.log.Printf("hover: add of %s had succeeded after all; not repeating", a.fqdn)
+					return *e, nil
 				}
 			}
-			fmt.Printf("Action Stack post (%02d): [%s]\n", actnum, a)
-		} else {
-			c.log.Printf("domain %s not found", a.domain)
-			return fmt.Errorf("Domain %s not found in domains", a.domain)
 		}
 	}
-	return nil
+
+	return created, err
+}
+
+// postEntry makes a single attempt at adding an entry, returning the entry as created if Hover
+// echoes it back
+func (c *Client) postEntry(ctx context.Context, call apiCall) (Entry, error) {
+	resp, err := c.do(ctx, call)
+	if err != nil {
+		return Entry{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := readResponse(resp)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	var created Entry
+	_ = json.Unmarshal(body, &created)
+	return created, nil
+}
+
+// putEntry makes an in-place update of an entry, returning the entry as updated if Hover echoes
+// it back
+func (c *Client) putEntry(ctx context.Context, id string, values url.Values) (Entry, error) {
+	resp, err := c.do(ctx, apiCall{method: http.MethodPut, url: c.APIURLEntry(id), values: values})
+	if err != nil {
+		return Entry{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := readResponse(resp)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	var updated Entry
+	_ = json.Unmarshal(body, &updated)
+	return updated, nil
+}
+
+// deleteEntry removes a single entry from the domain by its ID, unless Hover manages it or the
+// client's Ownership policy refuses (ErrNotOwned)
+func (c *Client) deleteEntry(ctx context.Context, domain *Domain, e Entry) error {
+	if err := c.mayDelete(ctx, domain.DomainName, e); err != nil {
+		return err
+	}
+	return c.removeEntry(ctx, domain, e)
+}
+
+// mayDelete checks that the entry of the domain can be deleted: Hover doesn't manage it, and the
+// client's Ownership policy, if any, allows it
+func (c *Client) mayDelete(ctx context.Context, domain string, e Entry) error {
+	if hoverManaged(e) {
+		return errHoverManaged(domain, e)
+	}
+	return c.mayChange(ctx, domain, e)
+}
+
+// removeEntry removes a single entry from the domain by its ID, unchecked.  A 404 means the entry
+// is already gone, such as when a retried delete had actually succeeded the first time.
+func (c *Client) removeEntry(ctx context.Context, domain *Domain, e Entry) error {
+	err := c.HTTPDeleteContext(ctx, fmt.Sprintf("%s/%s", c.APIURLDNS(domain.ID), e.ID))
+
+	var he *HTTPError
+	if errors.As(err, &he) && he.StatusCode == http.StatusNotFound {
+		c.log.Printf("hover: entry %s of %s already gone", e.ID, domain.DomainName)
+		return nil
+	}
+	return err
+}
+
+// updateEntry attempts an in-place update of the entry matching the Action, falling back to a
+// delete/add if Hover refuses the update, or a plain add if there's no entry to update.  The
+// strategy used is returned so that it can be reported.
+//
+// If several entries share the name and type (ie round-robin A records), it's not clear which to
+// update: that's refused as ErrAmbiguous.  UpsertRecord can replace or add to such a set.
+func (c *Client) updateEntry(ctx context.Context, domain *Domain, a Action) (UpdateStrategy, error) {
+	existing := domain.FindEntries(a.fqdn, a.rrtype, "")
+	if a.id != "" {
+		if existing = a.matching(*domain); len(existing) == 0 {
+			return UpdateNone, fmt.Errorf("hover: entry %s (%s %s) in %s: %w", a.id, a.rrtype, a.fqdn, domain.DomainName, ErrRecordNotFound)
+		}
+	}
+	switch len(existing) {
+	case 0:
+		c.log.Printf(`NOTE: FQDN "%s" (type "%s") in domain "%s" not found; adding`, a.fqdn, a.rrtype, domain.DomainName)
+		_, err := c.addEntry(ctx, domain, a)
+		return UpdateCreate, err
+	case 1:
+		_, strategy, err := c.replaceEntry(ctx, domain, existing[0], a)
+		return strategy, err
+	}
+	return UpdateNone, fmt.Errorf("hover: %d records are %s %s in %s; use UpsertRecord to replace them all: %w", len(existing), a.rrtype, a.fqdn, domain.DomainName, ErrAmbiguous)
+}
+
+// replaceEntry attempts an in-place update of the given entry to match the Action, falling back
+// to a delete/add if Hover refuses the update.  The entry as it ends up is returned, which has a
+// new ID after a delete/add.  An entry that Hover manages is never deleted, so a refused update
+// of one is ErrNotOwned.
+func (c *Client) replaceEntry(ctx context.Context, domain *Domain, e Entry, a Action) (Entry, UpdateStrategy, error) {
+	if err := a.checkTTL(); err != nil {
+		return Entry{}, UpdateNone, err
+	}
+	if err := c.mayChange(ctx, domain.DomainName, e); err != nil {
+		return Entry{}, UpdateNone, err
+	}
+
+	updated, err := c.putEntry(ctx, e.ID, a.withTTL(url.Values{"content": {a.Content()}}))
+	if err == nil {
+		if updated.ID == "" { // not echoed back
+			updated = e
+			updated.Content = a.Content()
+			if a.ttl != 0 {
+				updated.TTL = int(a.ttl)
+			}
+		}
+		return updated, UpdateInPlace, nil
+	}
+
+	var he *HTTPError
+	if !errors.As(err, &he) || !refusedUpdate[he.StatusCode] {
+		return Entry{}, UpdateInPlace, err
+	}
+
+	if hoverManaged(e) { // a delete/add would lose Hover's hold on it
+		return Entry{}, UpdateInPlace, fmt.Errorf("hover: in-place update refused (%s): %w", he.Status, errHoverManaged(domain.DomainName, e))
+	}
+	c.log.Printf(`in-place update of entry %s refused (%s: %s); falling back to delete/add`, e.ID, he.Status, he.Body)
+	if err := c.removeEntry(ctx, domain, e); err != nil { // already checked, and replaced rather than lost
+		return Entry{}, UpdateReplace, err
+	}
+	created, err := c.addEntry(ctx, domain, a)
+	return created, UpdateReplace, err
 }
@@ -0,0 +1,40 @@
+package hoverdnsapi_test
+
+import (
+	"testing"
+
+	"github.com/chickenandpork/hoverdnsapi" // to ensure testing without extra access
+	"github.com/stretchr/testify/assert"
+)
+
+// TestActionContent checks that the type-specific fields of an Action are rendered into the
+// content string in the same form that Hover shows in Entry.Content
+func TestActionContent(t *testing.T) {
+	var tests = []struct {
+		desc     string
+		action   hoverdnsapi.Action
+		expected string
+	}{
+		{"TXT", hoverdnsapi.NewAction(hoverdnsapi.Add, "_acme-challenge.example.com", "example.com", "ABCDE", 300), "ABCDE"},
+		{"A", hoverdnsapi.NewRecordAction(hoverdnsapi.Add, "www.example.com", "example.com", "a", "192.0.2.1", 300), "192.0.2.1"},
+		{"MX", hoverdnsapi.NewRecordAction(hoverdnsapi.Add, "mail.example.com", "example.com", "MX", "mx.example.com", 300).WithPriority(10), "10 mx.example.com"},
+		{"MX preformatted", hoverdnsapi.NewRecordAction(hoverdnsapi.Add, "mail.example.com", "example.com", "MX", "20 mx.example.com", 300).WithPriority(10), "20 mx.example.com"},
+		{"SRV", hoverdnsapi.NewRecordAction(hoverdnsapi.Add, "_sip._tcp.example.com", "example.com", "SRV", "sip.example.com", 300).WithPriority(10).WithWeight(5).WithPort(5060), "10 5 5060 sip.example.com"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.action.Content())
+		})
+	}
+}
+
+// TestValidRecordType confirms the record types accepted (and some not)
+func TestValidRecordType(t *testing.T) {
+	for _, rrtype := range []string{"A", "AAAA", "CAA", "CNAME", "MX", "NS", "SRV", "TXT", "txt"} {
+		assert.Truef(t, hoverdnsapi.ValidRecordType(rrtype), "type %s should be valid", rrtype)
+	}
+	for _, rrtype := range []string{"", "PTR", "SOA", "bogus"} {
+		assert.Falsef(t, hoverdnsapi.ValidRecordType(rrtype), "type %s should be invalid", rrtype)
+	}
+}
@@ -0,0 +1,207 @@
+package hoverdnsapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// DefaultCacheTTL is how long the domains and entries read from Hover are trusted before being
+// read again, unless overridden by WithCacheTTL
+const DefaultCacheTTL = 5 * time.Minute
+
+// WithCacheTTL sets how long the domains and entries read from Hover are trusted; zero re-reads
+// them whenever they're needed.  Changes made through the client invalidate the entries of the
+// domain changed regardless.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(c *Client) error {
+		if ttl < 0 {
+			return fmt.Errorf("hover: WithCacheTTL: negative TTL %s", ttl)
+		}
+		c.cacheTTL = ttl
+		return nil
+	}
+}
+
+// fresh reports whether something fetched at the given time can still be used; call with the
+// lock held
+func (c *Client) fresh(fetched time.Time) bool {
+	if c.offline {
+		return true
+	}
+	return !fetched.IsZero() && c.cacheTTL > 0 && time.Since(fetched) < c.cacheTTL
+}
+
+// domainsFresh reports whether the list of domains can be used without reading it again
+func (c *Client) domainsFresh() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.domains.Domains) > 0 && c.fresh(c.domainsFetched)
+}
+
+// entriesFresh reports whether the entries of the domain can be used without reading them again
+func (c *Client) entriesFresh(domainname string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.fresh(c.entriesFetched[domainname])
+}
+
+// keepEntries carries the cached entries of each domain over to a newly-read list of domains that
+// lacks them, and notes when entries that are included were read; call with the lock held
+func (c *Client) keepEntries(domains []Domain) {
+	fetched := make(map[string]time.Time)
+	for n, d := range domains {
+		if len(d.Entries) > 0 {
+			fetched[d.DomainName] = time.Now()
+			continue
+		}
+		for _, old := range c.domains.Domains {
+			if old.DomainName == d.DomainName && !c.entriesFetched[d.DomainName].IsZero() {
+				domains[n].Entries = old.Entries
+				fetched[d.DomainName] = c.entriesFetched[d.DomainName]
+			}
+		}
+	}
+	c.entriesFetched = fetched
+}
+
+// InvalidateDomain discards the cached entries of the domain, so that they're read again when
+// next needed.  This is done after every change made through the client; call it if the domain
+// is changed by other means.
+func (c *Client) InvalidateDomain(domainname string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.offline {
+		return
+	}
+	delete(c.entriesFetched, domainname)
+	for n, d := range c.domains.Domains {
+		if d.DomainName == domainname {
+			c.domains.Domains[n].Entries = nil
+		}
+	}
+}
+
+// Invalidate discards the whole cache: the domains and all their entries
+func (c *Client) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.offline {
+		return
+	}
+	c.domains = DomainList{}
+	c.domainsFetched = time.Time{}
+	c.entriesFetched = nil
+}
+
+// Refresh reads the domains and the entries of every domain from Hover, replacing the cache
+func (c *Client) Refresh() error {
+	return c.RefreshContext(context.Background())
+}
+
+// RefreshContext is Refresh bound to a context for cancellation and deadlines
+func (c *Client) RefreshContext(ctx context.Context) error {
+	if c.offline {
+		return nil
+	}
+	c.Invalidate()
+	if err := c.FillDomainsContext(ctx); err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	names := make([]string, 0, len(c.domains.Domains))
+	for _, d := range c.domains.Domains {
+		names = append(names, d.DomainName)
+	}
+	c.mu.RUnlock()
+
+	for _, name := range names {
+		if err := c.GetDomainEntriesContext(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snapshot is the cached state of the account at a point in time, for saving and later use by a
+// client running offline (see WithSnapshot)
+type Snapshot struct {
+	Taken   time.Time `json:"taken"`
+	Domains []Domain  `json:"domains"`
+}
+
+// Snapshot returns a copy of the cache; Refresh first to include every domain's entries
+func (c *Client) Snapshot() Snapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return Snapshot{Taken: c.domainsFetched, Domains: copyDomains(c.domains.Domains)}
+}
+
+// SaveSnapshot writes the cache to a file.  The file is readable only by its owner, since it
+// holds the account's contact details.
+func (c *Client) SaveSnapshot(filename string) error {
+	data, err := json.MarshalIndent(c.Snapshot(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+		return fmt.Errorf("hover: saving snapshot: %w", err)
+	}
+	return nil
+}
+
+// ReadSnapshot reads a snapshot written by SaveSnapshot
+func ReadSnapshot(filename string) (*Snapshot, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("hover: reading snapshot: %w", err)
+	}
+
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("hover: parsing snapshot %s: %w", filename, err)
+	}
+	return &s, nil
+}
+
+// WithSnapshot runs the client offline from a snapshot: domains and entries are served from the
+// snapshot without contacting Hover, and anything that would need Hover (a login, or any change)
+// fails with ErrOffline.  No credentials are needed.
+func WithSnapshot(snapshot *Snapshot) Option {
+	return func(c *Client) error {
+		if snapshot == nil {
+			return errors.New("hover: WithSnapshot: nil snapshot")
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.offline = true
+		c.domains = DomainList{Succeeded: true, Domains: copyDomains(snapshot.Domains)}
+		c.domainsFetched = snapshot.Taken
+		c.entriesFetched = make(map[string]time.Time)
+		for _, d := range c.domains.Domains {
+			c.entriesFetched[d.DomainName] = snapshot.Taken
+		}
+		return nil
+	}
+}
+
+// copyDomains copies the domains, including their entries, so the copy can be used freely
+func copyDomains(domains []Domain) []Domain {
+	copied := make([]Domain, 0, len(domains))
+	for _, d := range domains {
+		d.Entries = append([]Entry(nil), d.Entries...)
+		copied = append(copied, d)
+	}
+	return copied
+}
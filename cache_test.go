@@ -0,0 +1,134 @@
+package hoverdnsapi_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chickenandpork/hoverdnsapi" // to ensure testing without extra access
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCacheInvalidation checks that a change is seen by a later action on the same domain
+func TestCacheInvalidation(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+
+	assert.NoError(t, client.DoActions(
+		hoverdnsapi.NewAction(hoverdnsapi.Add, "test1.example.com", "example.com", "ABCDE", 300),
+		hoverdnsapi.NewAction(hoverdnsapi.Delete, "test1.example.com", "example.com", "ABCDE", 300),
+	))
+	_, ok := findEntry(srv.Entries("example.com"), "test1", "TXT")
+	assert.False(t, ok, "delete didn't see the entry just added")
+}
+
+// TestCacheTTL checks that the domain list is only re-read once stale
+func TestCacheTTL(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		opts  []hoverdnsapi.Option
+		reads int
+	}{
+		{name: "default", reads: 1},
+		{name: "zero", opts: []hoverdnsapi.Option{hoverdnsapi.WithCacheTTL(0)}, reads: 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, client := newTestClient(t, tc.opts...)
+			defer srv.Close()
+
+			for n := 0; n < 2; n++ {
+				assert.NoError(t, client.DoActions(hoverdnsapi.NewAction(hoverdnsapi.Delete, "www.example.com", "example.com", "", 0)))
+			}
+			assert.Equal(t, tc.reads, countRequests(srv, "GET /api/domains"))
+		})
+	}
+
+	_, err := hoverdnsapi.New(hoverdnsapi.WithCredentials("scott", "tiger"), hoverdnsapi.WithCacheTTL(-1))
+	assert.Error(t, err)
+}
+
+// TestCacheNewDomain checks that a domain added since the list was read is found, not reported
+// missing until the cache expires
+func TestCacheNewDomain(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+	assert.NoError(t, client.FillDomains())
+
+	srv.AddDomain(hoverdnsapi.Domain{DomainName: "example.net", Entries: []hoverdnsapi.Entry{
+		{Name: "www", Type: "CNAME", Content: "example.net"},
+	}})
+	assert.NoError(t, client.Delete("www.example.net", "example.net"))
+	_, ok := findEntry(srv.Entries("example.net"), "www", "CNAME")
+	assert.False(t, ok, "record of the new domain not deleted")
+	assert.Equal(t, 2, countRequests(srv, "GET /api/domains"))
+
+	srv.AddDomain(hoverdnsapi.Domain{DomainName: "example.org"})
+	d, err := client.GetDomain("example.org")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "example.org", d.DomainName)
+	}
+
+	// a domain that really is missing is read for once, then reported
+	before := countRequests(srv, "GET /api/domains")
+	_, err = client.GetDomain("example.invalid")
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrDomainNotFound), "expected ErrDomainNotFound, got %v", err)
+	assert.Equal(t, before+1, countRequests(srv, "GET /api/domains"))
+}
+
+// TestRefresh checks that Refresh reads every domain's entries, and InvalidateDomain drops them
+func TestRefresh(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+
+	assert.NoError(t, client.Refresh())
+	d, ok := client.GetDomainByName("example.com")
+	if assert.True(t, ok) {
+		assert.Len(t, d.Entries, 2)
+	}
+
+	client.InvalidateDomain("example.com")
+	d, ok = client.GetDomainByName("example.com")
+	if assert.True(t, ok, "invalidating entries lost the domain") {
+		assert.Len(t, d.Entries, 0)
+	}
+}
+
+// TestSnapshot checks that a saved snapshot can be served offline, without credentials
+func TestSnapshot(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "hoverdnsapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "snapshot.json")
+
+	assert.NoError(t, client.Refresh())
+	assert.NoError(t, client.SaveSnapshot(filename))
+	snapshot, err := hoverdnsapi.ReadSnapshot(filename)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	before := len(srv.Requests())
+	offline, err := hoverdnsapi.New(hoverdnsapi.WithSnapshot(snapshot), hoverdnsapi.WithLogger(&hoverdnsapi.NopLogger{}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, offline.FillDomains())
+	assert.NoError(t, offline.GetDomainEntries("example.com"))
+	d, ok := offline.GetDomainByName("example.com")
+	if assert.True(t, ok) {
+		assert.Len(t, d.Entries, 2)
+	}
+
+	err = offline.DoActions(hoverdnsapi.NewAction(hoverdnsapi.Add, "test1.example.com", "example.com", "ABCDE", 300))
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrOffline), "expected ErrOffline: %v", err)
+	_, err = offline.GetAuth()
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrOffline), "expected ErrOffline: %v", err)
+	assert.Equal(t, before, len(srv.Requests()), "offline client contacted Hover")
+}
@@ -0,0 +1,516 @@
+package hoverdnsapi_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"testing"
+
+	"github.com/chickenandpork/hoverdnsapi" // to ensure testing without extra access
+	"github.com/chickenandpork/hoverdnsapi/hovertest"
+	"github.com/stretchr/testify/assert"
+)
+
+// fastRetry is DefaultRetryPolicy without the waiting, to keep tests quick
+var fastRetry = hoverdnsapi.RetryPolicy{
+	MaxAttempts:      3,
+	InitialBackoff:   time.Millisecond,
+	RetryableStatus:  hoverdnsapi.DefaultRetryPolicy.RetryableStatus,
+	RetryableMethods: hoverdnsapi.DefaultRetryPolicy.RetryableMethods,
+}
+
+// countRequests counts the requests the fake has seen, ie "GET /api/domains"
+func countRequests(srv *hovertest.Server, request string) (count int) {
+	for _, r := range srv.Requests() {
+		if r == request {
+			count++
+		}
+	}
+	return count
+}
+
+// newTestClient starts a hovertest fake with one user and one domain, returning the fake and a
+// client pointed at it, with any extra options.  Close() the fake when done.
+func newTestClient(t *testing.T, opts ...hoverdnsapi.Option) (*hovertest.Server, *hoverdnsapi.Client) {
+	srv := hovertest.NewServer()
+	srv.AddUser("scott", "tiger")
+	srv.AddDomain(hoverdnsapi.Domain{DomainName: "example.com", Entries: []hoverdnsapi.Entry{
+		{Name: "@", Type: "A", Content: "192.0.2.1", Default: true},
+		{Name: "www", Type: "CNAME", Content: "example.com"},
+	}})
+
+	client, err := hoverdnsapi.New(append([]hoverdnsapi.Option{
+		hoverdnsapi.WithCredentials("scott", "tiger"),
+		hoverdnsapi.WithBaseURL(srv.BaseURL()),
+		hoverdnsapi.WithTimeout(10 * time.Second),
+		hoverdnsapi.WithLogger(&hoverdnsapi.NopLogger{}),
+		hoverdnsapi.WithRetryPolicy(fastRetry),
+	}, opts...)...)
+	if err != nil {
+		srv.Close()
+		t.Fatalf("creating client for fake: %v", err)
+	}
+	return srv, client
+}
+
+// findEntry is a convenience to find an entry by name and type in a list from the fake
+func findEntry(entries []hoverdnsapi.Entry, name, rrtype string) (hoverdnsapi.Entry, bool) {
+	for _, e := range entries {
+		if e.Name == name && e.Type == rrtype {
+			return e, true
+		}
+	}
+	return hoverdnsapi.Entry{}, false
+}
+
+// TestFillDomains checks login and the domain list against the fake
+func TestFillDomains(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+
+	if assert.NoError(t, client.FillDomains()) {
+		d, ok := client.GetDomainByName("example.com")
+		if assert.True(t, ok) {
+			assert.Equal(t, "example.com", d.DomainName)
+		}
+	}
+
+	auth, err := client.GetAuth()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, auth)
+}
+
+// TestAuthFailure checks that a refused login is recognizable as such
+func TestAuthFailure(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+	srv.FailAuth(true)
+
+	err := client.FillDomains()
+	assert.Truef(t, errors.Is(err, hoverdnsapi.ErrAuthFailed), "expected ErrAuthFailed, got %v", err)
+}
+
+// TestAuthNotLogged checks that the auth cookie, as good as a password, never reaches the log
+func TestAuthNotLogged(t *testing.T) {
+	var logged bytes.Buffer
+	srv, client := newTestClient(t, hoverdnsapi.WithLogger(log.New(&logged, "", 0)))
+	defer srv.Close()
+
+	auth, err := client.GetAuth()
+	if assert.NoError(t, err) && assert.NotEmpty(t, auth) {
+		_, err = client.GetAuth() // once more, finding the cookie already in the jar
+		assert.NoError(t, err)
+		assert.NotContains(t, logged.String(), auth)
+	}
+}
+
+// TestDoActions runs add, update, and delete of several record types through the fake
+func TestDoActions(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+
+	err := client.DoActions(
+		hoverdnsapi.NewAction(hoverdnsapi.Add, "_acme-challenge.example.com", "example.com", "ABCDE", 300),
+		hoverdnsapi.NewRecordAction(hoverdnsapi.Add, "host.example.com", "example.com", "A", "192.0.2.2", 300),
+		hoverdnsapi.NewRecordAction(hoverdnsapi.Add, "mail.example.com", "example.com", "MX", "mx.example.com", 300).WithPriority(10),
+	)
+	if assert.NoError(t, err) {
+		entries := srv.Entries("example.com")
+		if e, ok := findEntry(entries, "_acme-challenge", "TXT"); assert.True(t, ok) {
+			assert.Equal(t, "ABCDE", e.Content)
+		}
+		if e, ok := findEntry(entries, "host", "A"); assert.True(t, ok) {
+			assert.Equal(t, "192.0.2.2", e.Content)
+		}
+		if e, ok := findEntry(entries, "mail", "MX"); assert.True(t, ok) {
+			assert.Equal(t, "10 mx.example.com", e.Content)
+		}
+	}
+
+	before, _ := findEntry(srv.Entries("example.com"), "host", "A")
+	results, err := client.DoActionsResults(context.Background(), hoverdnsapi.NewRecordAction(hoverdnsapi.Update, "host.example.com", "example.com", "A", "192.0.2.3", 300))
+	if assert.NoError(t, err) && assert.Len(t, results, 1) {
+		assert.Equal(t, hoverdnsapi.UpdateInPlace, results[0].Strategy)
+		after, _ := findEntry(srv.Entries("example.com"), "host", "A")
+		assert.Equal(t, before.ID, after.ID)
+		assert.Equal(t, "192.0.2.3", after.Content)
+	}
+
+	if assert.NoError(t, client.Delete("_acme-challenge.example.com", "example.com")) {
+		_, ok := findEntry(srv.Entries("example.com"), "_acme-challenge", "TXT")
+		assert.False(t, ok)
+	}
+}
+
+// TestUpdateFallback checks that an update refused by Hover (422) falls back to delete/add
+func TestUpdateFallback(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+	srv.Inject(hovertest.Fault{Method: http.MethodPut, Status: http.StatusUnprocessableEntity, Body: `{"succeeded":false,"error_code":"unactionable"}`})
+
+	before, _ := findEntry(srv.Entries("example.com"), "www", "CNAME")
+	results, err := client.DoActionsResults(context.Background(), hoverdnsapi.NewRecordAction(hoverdnsapi.Update, "www.example.com", "example.com", "CNAME", "other.example.com", 300))
+	if assert.NoError(t, err) && assert.Len(t, results, 1) {
+		assert.Equal(t, hoverdnsapi.UpdateReplace, results[0].Strategy)
+		after, ok := findEntry(srv.Entries("example.com"), "www", "CNAME")
+		if assert.True(t, ok) {
+			assert.NotEqual(t, before.ID, after.ID)
+			assert.Equal(t, "other.example.com", after.Content)
+		}
+	}
+
+	// Hover's own records are never deleted to be added anew, whether updated directly or by a plan
+	apex, _ := findEntry(srv.Entries("example.com"), "@", "A")
+	err = client.DoActions(hoverdnsapi.NewRecordAction(hoverdnsapi.Update, "example.com", "example.com", "A", "192.0.2.9", 0))
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrNotOwned), "expected ErrNotOwned: %v", err)
+	assert.Contains(t, srv.Entries("example.com"), apex)
+
+	srv.AddDomain(hoverdnsapi.Domain{DomainName: "example.net", Entries: []hoverdnsapi.Entry{
+		{Name: "@", Type: "MX", Content: "10 mx.hover.com.cust.hostedemail.com", CanRevert: true},
+	}})
+	mx := srv.Entries("example.net")[0]
+	actions, err := client.Plan("example.net", hoverdnsapi.DesiredState{Records: []hoverdnsapi.Entry{
+		{Name: "@", Type: "MX", Content: "10 mx.mine.example"},
+	}})
+	if assert.NoError(t, err) {
+		err = client.DoActions(actions...)
+		assert.True(t, errors.Is(err, hoverdnsapi.ErrNotOwned), "expected ErrNotOwned: %v", err)
+	}
+	assert.Equal(t, []hoverdnsapi.Entry{mx}, srv.Entries("example.net"))
+}
+
+// TestDoActionsFailures checks that a refused mutation is reported, without stopping the other
+// actions
+func TestDoActionsFailures(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+	srv.Inject(hovertest.Fault{Method: http.MethodPost, Path: "/api/domains/", Status: http.StatusBadRequest, Body: `{"succeeded":false}`, Times: 1})
+
+	err := client.DoActions(
+		hoverdnsapi.NewAction(hoverdnsapi.Add, "first.example.com", "example.com", "ABCDE", 300),
+		hoverdnsapi.NewAction(hoverdnsapi.Add, "second.example.com", "example.com", "ABCDE", 300),
+		hoverdnsapi.NewAction(hoverdnsapi.Add, "third.example.org", "example.org", "ABCDE", 300),
+	)
+
+	var ae *hoverdnsapi.ActionsError
+	if assert.True(t, errors.As(err, &ae)) && assert.Len(t, ae.Failed(), 2) {
+		assert.True(t, errors.Is(ae.Failed()[0].Err, hoverdnsapi.ErrRejected))
+		assert.True(t, errors.Is(ae.Failed()[1].Err, hoverdnsapi.ErrDomainNotFound))
+	}
+	_, ok := findEntry(srv.Entries("example.com"), "second", "TXT")
+	assert.True(t, ok)
+}
+
+// TestRetry checks that temporary failures are retried, and permanent ones are not
+func TestRetry(t *testing.T) {
+	t.Run("GET retried", func(t *testing.T) {
+		srv, client := newTestClient(t)
+		defer srv.Close()
+		srv.Inject(hovertest.Fault{Method: http.MethodGet, Path: "/api/domains", Status: http.StatusServiceUnavailable, Times: 2})
+
+		assert.NoError(t, client.FillDomains())
+		assert.Equal(t, 3, countRequests(srv, "GET /api/domains"))
+	})
+
+	t.Run("GET gives up", func(t *testing.T) {
+		srv, client := newTestClient(t)
+		defer srv.Close()
+		srv.Inject(hovertest.Fault{Method: http.MethodGet, Path: "/api/domains", Status: http.StatusServiceUnavailable})
+
+		err := client.FillDomains()
+		assert.True(t, errors.Is(err, hoverdnsapi.ErrRejected))
+		assert.Equal(t, fastRetry.MaxAttempts, countRequests(srv, "GET /api/domains"))
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		srv, client := newTestClient(t)
+		defer srv.Close()
+		srv.Inject(hovertest.Fault{Method: http.MethodGet, Path: "/api/domains", Status: http.StatusBadRequest})
+
+		assert.Error(t, client.FillDomains())
+		assert.Equal(t, 1, countRequests(srv, "GET /api/domains"))
+	})
+
+	t.Run("add retried", func(t *testing.T) {
+		srv, client := newTestClient(t)
+		defer srv.Close()
+		srv.Inject(hovertest.Fault{Method: http.MethodPost, Path: "/api/domains/", Status: http.StatusInternalServerError, Times: 1})
+
+		assert.NoError(t, client.DoActions(hoverdnsapi.NewAction(hoverdnsapi.Add, "test1.example.com", "example.com", "ABCDE", 300)))
+		count := 0
+		for _, e := range srv.Entries("example.com") {
+			if e.Name == "test1" {
+				count++
+			}
+		}
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("add not duplicated", func(t *testing.T) {
+		srv, client := newTestClient(t)
+		defer srv.Close()
+		srv.Inject(hovertest.Fault{Method: http.MethodPost, Path: "/api/domains/", Status: http.StatusBadGateway, Lost: true, Times: 1})
+
+		assert.NoError(t, client.DoActions(hoverdnsapi.NewAction(hoverdnsapi.Add, "test1.example.com", "example.com", "ABCDE", 300)))
+		count := 0
+		for _, e := range srv.Entries("example.com") {
+			if e.Name == "test1" {
+				count++
+			}
+		}
+		assert.Equal(t, 1, count)
+		assert.Equal(t, 1, countRequests(srv, "POST /api/domains/"+mustDomainID(t, srv, "example.com")+"/dns"))
+	})
+
+	t.Run("add waits as asked", func(t *testing.T) {
+		srv, client := newTestClient(t, hoverdnsapi.WithRateLimit(0, 0))
+		defer srv.Close()
+		srv.Inject(hovertest.Fault{
+			Method: http.MethodPost,
+			Path:   "/api/domains/",
+			Status: http.StatusTooManyRequests,
+			Header: http.Header{"Retry-After": []string{"1"}},
+			Times:  1,
+		})
+
+		start := time.Now()
+		assert.NoError(t, client.DoActions(hoverdnsapi.NewAction(hoverdnsapi.Add, "test1.example.com", "example.com", "ABCDE", 300)))
+		assert.True(t, time.Since(start) >= time.Second, "add retried after only %s", time.Since(start))
+		assert.Equal(t, 2, countRequests(srv, "POST /api/domains/"+mustDomainID(t, srv, "example.com")+"/dns"))
+		assert.True(t, client.Stats().RateLimitWait >= time.Second, "Retry-After wait %s not counted", client.Stats().RateLimitWait)
+	})
+}
+
+// mustDomainID looks up the ID the fake assigned to a domain
+func mustDomainID(t *testing.T, srv *hovertest.Server, name string) string {
+	d, ok := srv.Domain(name)
+	if !ok {
+		t.Fatalf("domain %s not in fake", name)
+	}
+	return d.ID
+}
+
+// TestBackoff checks the growth and cap of the wait between attempts
+func TestBackoff(t *testing.T) {
+	policy := hoverdnsapi.RetryPolicy{MaxAttempts: 5, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	assert.Equal(t, 100*time.Millisecond, policy.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.Backoff(2))
+	assert.Equal(t, 400*time.Millisecond, policy.Backoff(3))
+	assert.Equal(t, time.Second, policy.Backoff(5))
+
+	policy.Jitter = 0.5
+	for n := 0; n < 20; n++ {
+		wait := policy.Backoff(1)
+		assert.True(t, wait >= 50*time.Millisecond && wait <= 150*time.Millisecond, "jittered wait %s out of range", wait)
+	}
+}
+
+// TestRateLimit checks that requests beyond the burst are held back, and that the wait is counted
+func TestRateLimit(t *testing.T) {
+	srv, client := newTestClient(t, hoverdnsapi.WithRateLimit(20, 1))
+	defer srv.Close()
+
+	start := time.Now()
+	for n := 0; n < 4; n++ {
+		assert.NoError(t, client.FillDomains())
+	}
+	elapsed := time.Since(start)
+
+	// login plus 4 GETs: 4 of the 5 wait ~50ms each for a token
+	stats := client.Stats()
+	assert.Equal(t, int64(5), stats.Requests)
+	assert.Equal(t, int64(0), stats.Retries)
+	assert.True(t, elapsed >= 150*time.Millisecond, "5 requests at 20/s took only %s", elapsed)
+	assert.True(t, stats.RateLimitWait >= 150*time.Millisecond, "rate limit wait %s too short", stats.RateLimitWait)
+
+	_, err := hoverdnsapi.New(hoverdnsapi.WithCredentials("scott", "tiger"), hoverdnsapi.WithRateLimit(-1, 1))
+	assert.Error(t, err)
+	_, err = hoverdnsapi.New(hoverdnsapi.WithCredentials("scott", "tiger"), hoverdnsapi.WithRateLimit(1, 0))
+	assert.Error(t, err)
+}
+
+// TestRetryAfter checks that a 429 holds off the retry for as long as Hover asks
+func TestRetryAfter(t *testing.T) {
+	srv, client := newTestClient(t, hoverdnsapi.WithRateLimit(0, 0))
+	defer srv.Close()
+	srv.Inject(hovertest.Fault{
+		Method: http.MethodGet,
+		Path:   "/api/domains",
+		Status: http.StatusTooManyRequests,
+		Header: http.Header{"Retry-After": []string{"1"}},
+		Times:  1,
+	})
+
+	start := time.Now()
+	assert.NoError(t, client.FillDomains())
+	assert.True(t, time.Since(start) >= time.Second, "retried after only %s", time.Since(start))
+
+	stats := client.Stats()
+	assert.Equal(t, int64(1), stats.Throttled)
+	assert.Equal(t, int64(1), stats.Retries)
+	assert.Equal(t, int64(3), stats.Requests)
+	assert.True(t, stats.RateLimitWait >= time.Second, "Retry-After wait %s not counted", stats.RateLimitWait)
+}
+
+// TestSecondFactor checks login to an account with 2FA, by TOTP secret and by prompt
+func TestSecondFactor(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+
+	t.Run("TOTP secret", func(t *testing.T) {
+		srv, client := newTestClient(t, hoverdnsapi.WithTOTPSecret(secret))
+		defer srv.Close()
+		srv.RequireSecondFactor("scott", hoverdnsapi.SecondFactorApp, secret)
+
+		assert.NoError(t, client.FillDomains())
+		assert.Equal(t, 1, countRequests(srv, "POST /api/login/2fa"))
+	})
+
+	t.Run("emailed code by prompt", func(t *testing.T) {
+		asked := ""
+		srv, client := newTestClient(t, hoverdnsapi.WithTOTPSecret(secret), hoverdnsapi.WithSecondFactorPrompt(func(ctx context.Context, method string) (string, error) {
+			asked = method
+			return "424242\n", nil
+		}))
+		defer srv.Close()
+		srv.RequireSecondFactor("scott", hoverdnsapi.SecondFactorEmail, "424242")
+
+		assert.NoError(t, client.FillDomains())
+		assert.Equal(t, hoverdnsapi.SecondFactorEmail, asked)
+	})
+
+	t.Run("no way to get a code", func(t *testing.T) {
+		srv, client := newTestClient(t)
+		defer srv.Close()
+		srv.RequireSecondFactor("scott", hoverdnsapi.SecondFactorApp, secret)
+
+		_, err := client.GetAuth()
+		assert.True(t, errors.Is(err, hoverdnsapi.ErrSecondFactorRequired), "expected ErrSecondFactorRequired: %v", err)
+		assert.Equal(t, 0, countRequests(srv, "POST /api/login/2fa"))
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		srv, client := newTestClient(t, hoverdnsapi.WithTOTPSecret("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"))
+		defer srv.Close()
+		srv.RequireSecondFactor("scott", hoverdnsapi.SecondFactorApp, secret)
+
+		_, err := client.GetAuth()
+		assert.True(t, errors.Is(err, hoverdnsapi.ErrAuthFailed), "expected ErrAuthFailed: %v", err)
+	})
+
+	_, err := hoverdnsapi.New(hoverdnsapi.WithCredentials("scott", "tiger"), hoverdnsapi.WithTOTPSecret("not base32!"))
+	assert.Error(t, err)
+}
+
+// TestReauth checks that a session expiring mid-operation is replaced by logging in again, once
+func TestReauth(t *testing.T) {
+	t.Run("401", func(t *testing.T) {
+		srv, client := newTestClient(t)
+		defer srv.Close()
+
+		assert.NoError(t, client.FillDomains())
+		srv.ExpireSessions()
+		assert.NoError(t, client.FillDomains())
+		assert.Equal(t, 2, countRequests(srv, "POST /api/login"))
+	})
+
+	t.Run("redirect to login", func(t *testing.T) {
+		srv, client := newTestClient(t)
+		defer srv.Close()
+		srv.RedirectToLogin(true)
+
+		assert.NoError(t, client.FillDomains())
+		srv.ExpireSessions()
+		assert.NoError(t, client.DoActions(hoverdnsapi.NewAction(hoverdnsapi.Add, "test1.example.com", "example.com", "ABCDE", 300)))
+		assert.Equal(t, 2, countRequests(srv, "POST /api/login"))
+		_, ok := findEntry(srv.Entries("example.com"), "test1", "TXT")
+		assert.True(t, ok, "add not replayed after login")
+	})
+
+	t.Run("not a redirect to sign in", func(t *testing.T) {
+		srv, client := newTestClient(t)
+		defer srv.Close()
+		srv.AddDomain(hoverdnsapi.Domain{DomainName: "login.example", Entries: []hoverdnsapi.Entry{
+			{Name: "signin", Type: "A", Content: "192.0.2.1"},
+		}})
+		srv.Inject(hovertest.Fault{Method: http.MethodDelete, Status: http.StatusFound, Header: http.Header{"Location": {"/help/login"}}})
+
+		entries, err := client.ListRecords("login.example", hoverdnsapi.RecordFilter{})
+		if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+			_ = client.HTTPDelete(client.APIURLEntry(entries[0].ID)) // redirected elsewhere, and fails
+		}
+		assert.Equal(t, 1, countRequests(srv, "POST /api/login"), "paths mentioning login taken as the sign-in page")
+	})
+
+	t.Run("login refused", func(t *testing.T) {
+		srv, client := newTestClient(t)
+		defer srv.Close()
+
+		assert.NoError(t, client.FillDomains())
+		srv.ExpireSessions()
+		srv.FailAuth(true)
+		err := client.FillDomains()
+		assert.True(t, errors.Is(err, hoverdnsapi.ErrAuthFailed), "expected ErrAuthFailed: %v", err)
+		assert.Equal(t, 2, countRequests(srv, "POST /api/login"))
+	})
+
+	t.Run("still refused", func(t *testing.T) {
+		srv, client := newTestClient(t)
+		defer srv.Close()
+
+		assert.NoError(t, client.FillDomains())
+		srv.Inject(hovertest.Fault{Method: http.MethodGet, Path: "/api/domains", Status: http.StatusForbidden})
+		err := client.FillDomains()
+		assert.True(t, errors.Is(err, hoverdnsapi.ErrAuthFailed), "expected ErrAuthFailed: %v", err)
+		assert.Equal(t, 2, countRequests(srv, "POST /api/login"), "logged in more than once more")
+	})
+}
+
+// TestConcurrentClient shares one client among goroutines; run with -race to be meaningful
+func TestConcurrentClient(t *testing.T) {
+	srv, client := newTestClient(t, hoverdnsapi.WithRateLimit(0, 0))
+	defer srv.Close()
+
+	// slow enough that the fills overlap, and should be deduplicated
+	srv.Inject(hovertest.Fault{Method: http.MethodGet, Path: "/api/domains", Delay: 200 * time.Millisecond, Times: 1})
+
+	const workers = 10
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, 3*workers)
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			<-start
+			errs <- client.FillDomains()
+			if d, ok := client.GetDomainByName("example.com"); ok {
+				d.Entries = append(d.Entries, hoverdnsapi.Entry{Name: "scribble"}) // a copy: harmless
+			}
+			errs <- client.GetDomainEntries("example.com")
+			errs <- client.DoActions(hoverdnsapi.NewAction(hoverdnsapi.Add, fmt.Sprintf("test%d.example.com", n), "example.com", "ABCDE", 300))
+		}(n)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, countRequests(srv, "POST /api/login"), "concurrent logins not deduplicated")
+	assert.Equal(t, 1, countRequests(srv, "GET /api/domains"), "concurrent fills not deduplicated")
+	for n := 0; n < workers; n++ {
+		_, ok := findEntry(srv.Entries("example.com"), fmt.Sprintf("test%d", n), "TXT")
+		assert.True(t, ok, "test%d not added", n)
+	}
+	if d, ok := client.GetDomainByName("example.com"); ok {
+		_, scribbled := findEntry(d.Entries, "scribble", "")
+		assert.False(t, scribbled, "GetDomainByName exposed the cached entries")
+	}
+}
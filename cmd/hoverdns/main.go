@@ -19,9 +19,12 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"time"
@@ -35,17 +38,39 @@ var version = "dev" // overwrite in production build if you ever want to ship th
 var (
 	onlyOneClient sync.Once
 	client        *hover.Client
+	clientErr     error
 )
 
-// getClient singletons a hover client
-func getClient(username, password, passfile string) *hover.Client {
+// getClient singletons a hover client, filling its domains
+func getClient(opts ...hover.Option) (*hover.Client, error) {
 	onlyOneClient.Do(func() {
-		fmt.Printf("logging in: u: %+v p: %+v f:%+v\n", username, password, passfile)
-		client = hover.NewClient(username, password, passfile, 30*time.Second)
-		client.FillDomains()
+		if client, clientErr = hover.New(opts...); clientErr == nil {
+			clientErr = client.FillDomains()
+		}
 	})
 
-	return client
+	return client, clientErr
+}
+
+// promptCode asks on the terminal for a 2FA code that can't be generated
+func promptCode(ctx context.Context, method string) (string, error) {
+	fmt.Fprintf(os.Stderr, "Hover %s verification code: ", method)
+	code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && code == "" {
+		return "", fmt.Errorf("reading %s code: %w", method, err)
+	}
+	return code, nil
+}
+
+// describeAction renders a planned change as a line of a plan: "+" to add, "~" to update, and
+// "-" to delete
+func describeAction(a hover.Action) string {
+	mark := map[hover.HoverAct]string{hover.Add: "+", hover.Update: "~", hover.Delete: "-", hover.DeleteAll: "-"}[a.Act()]
+	line := fmt.Sprintf("%s %s %s %s", mark, a.FQDN(), a.Type(), a.Content())
+	if a.TTL() != 0 {
+		line += fmt.Sprintf(" (ttl %d)", a.TTL())
+	}
+	return line
 }
 
 func main() {
@@ -53,37 +78,249 @@ func main() {
 		passfile string
 		password string
 		username string
+		baseURL  string
 		domains  cli.StringSlice
 		hostpart string
 		value    string
+		rrtype   string
 		ttl      uint
+		priority uint
+		weight   uint
+		port     uint
+		rate     float64
+		burst    int
+		totp     string
+		sessions string
+		snapshot string
+		owner    string
+		adopt    bool
 	)
 
+	// options builds the client options from the flags given
+	options := func() []hover.Option {
+		var opts []hover.Option
+		if owner != "" {
+			opts = append(opts, hover.WithOwnership(hover.Ownership{Owner: owner, Adopt: adopt}))
+		}
+		if snapshot != "" {
+			s, err := hover.ReadSnapshot(snapshot)
+			if err != nil {
+				return []hover.Option{func(*hover.Client) error { return err }}
+			}
+			return append(opts, hover.WithSnapshot(s))
+		}
+
+		opts = append(opts, hover.WithTimeout(30*time.Second))
+		if passfile != "" {
+			opts = append(opts, hover.WithCredentialsFile(passfile))
+		} else {
+			opts = append(opts, hover.WithCredentials(username, password))
+		}
+		if baseURL != "" {
+			opts = append(opts, hover.WithBaseURL(baseURL))
+		}
+		if sessions != "" {
+			opts = append(opts, hover.WithSessionStore(hover.NewFileSessionStore(sessions)))
+		}
+		if totp != "" {
+			opts = append(opts, hover.WithTOTPSecret(totp))
+		}
+		return append(opts, hover.WithRateLimit(rate, burst), hover.WithSecondFactorPrompt(promptCode))
+	}
+
+	// newAction builds an action from the record-related flags common to add/update/delete
+	newAction := func(action hover.HoverAct, fqdn, domain, value string) hover.Action {
+		return hover.NewRecordAction(action, fqdn, domain, rrtype, value, ttl).WithPriority(priority).WithWeight(weight).WithPort(port)
+	}
+
+	// plan reads the desired state and the domain it's of, and finds the changes to reach it
+	plan := func(c *cli.Context) ([]hover.Action, error) {
+		state, err := hover.ReadDesiredState(c.String("file"))
+		if err != nil {
+			return nil, err
+		}
+		var domain string
+		switch len(domains.Value()) {
+		case 0:
+		case 1:
+			domain = domains.Value()[0]
+		default:
+			return nil, fmt.Errorf("a desired state is of one domain, not %v", domains.Value())
+		}
+
+		client, err := getClient(options()...)
+		if err != nil {
+			return nil, err
+		}
+		actions, err := client.PlanContext(c.Context, domain, *state)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range actions {
+			fmt.Println(describeAction(a))
+		}
+		fmt.Printf("%d change(s) to %s\n", len(actions), c.String("file"))
+		return actions, nil
+	}
+	stateFlag := &cli.StringFlag{Name: "file", Usage: "desired state of the domain (YAML, or JSON if *.json)", Required: true}
+
 	app := &cli.App{
 		Commands: []*cli.Command{
 			// "info" dumps JSON of the remote DNS data to confirm it authenticates
 			{Name: "info",
 				Aliases: []string{"q", "check"},
 				Usage:   "Check info about a domain; also confirms access",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "save-snapshot", Usage: "save every domain and record to this file, for later use with --snapshot"},
+				},
 				Action: func(c *cli.Context) error {
 					fmt.Printf("info %v\n", domains.Value())
-					if client := getClient(username, password, passfile); client != nil {
-						for _, d := range domains.Value() {
-							if do, ok := client.GetDomainByName(d); ok {
-								fmt.Printf("Domain: %s ==> %#v", d, do)
-							} else {
-								fmt.Printf("Domain: %s not found\n", d)
+					client, err := getClient(options()...)
+					if err != nil {
+						return fmt.Errorf("nope, Hover not instantiated: %w", err)
+					}
+					if save := c.String("save-snapshot"); save != "" {
+						if err := client.RefreshContext(c.Context); err != nil {
+							return err
+						}
+						if err := client.SaveSnapshot(save); err != nil {
+							return err
+						}
+					}
+					for _, d := range domains.Value() {
+						if do, ok := client.GetDomainByName(d); ok {
+							fmt.Printf("Domain: %s ==> %#v", d, do)
+						} else {
+							fmt.Printf("Domain: %s not found\n", d)
+						}
+					}
+
+					return nil
+				},
+			},
+
+			// "export" writes each domain as a BIND zone file, to stdout or a file per domain
+			{Name: "export",
+				Usage: "export each domain as a BIND zone file",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "dir", Usage: "write each domain to <domain>.zone in this directory, rather than to stdout"},
+				},
+				Action: func(c *cli.Context) error {
+					client, err := getClient(options()...)
+					if err != nil {
+						return err
+					}
+					for _, d := range domains.Value() {
+						if c.String("dir") == "" {
+							if err := client.ExportZoneContext(c.Context, os.Stdout, d); err != nil {
+								return err
 							}
+							continue
+						}
+
+						f, err := os.Create(filepath.Join(c.String("dir"), d+".zone"))
+						if err != nil {
+							return err
+						}
+						if err := client.ExportZoneContext(c.Context, f, d); err != nil {
+							f.Close()
+							return err
+						}
+						if err := f.Close(); err != nil {
+							return err
 						}
-					} else {
-						fmt.Println("nope, Hover not instantiated")
-						return fmt.Errorf("nope, Hover not instantiated")
 					}
+					return nil
+				},
+			},
 
+			// "snapshot" commits every domain to a git repository, to keep a history of changes
+			{Name: "snapshot",
+				Usage: "commit every domain, as JSON and a BIND zone file, to a git repository",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "dir", Usage: "directory of the git repository, made if need be", Required: true, EnvVars: []string{"HOVER_HISTORY_DIR"}},
+				},
+				Action: func(c *cli.Context) error {
+					client, err := getClient(options()...)
+					if err != nil {
+						return err
+					}
+					commit, err := client.CommitHistoryContext(c.Context, c.String("dir"))
+					if err != nil {
+						return err
+					}
+					if !commit.Changed {
+						fmt.Println("no changes")
+						return nil
+					}
+					fmt.Print(commit.Message)
 					return nil
 				},
 			},
 
+			// "import" reads a BIND zone file into a domain, adding the records it lacks
+			{Name: "import",
+				Usage: "import a BIND zone file into a domain, adding the records it lacks",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "file", Usage: "zone file to read", Required: true},
+					&cli.BoolFlag{Name: "dry-run", Usage: "only show the records that would be added"},
+				},
+				Action: func(c *cli.Context) error {
+					if len(domains.Value()) != 1 {
+						return fmt.Errorf("import needs exactly one domain, not %v", domains.Value())
+					}
+					d := domains.Value()[0]
+
+					entries, err := hover.ParseZoneFile(c.String("file"), d)
+					if err != nil {
+						return err
+					}
+					client, err := getClient(options()...)
+					if err != nil {
+						return err
+					}
+
+					actions, err := client.ImportZoneContext(c.Context, d, entries, c.Bool("dry-run"))
+					verb, summary := "add", "added"
+					if c.Bool("dry-run") {
+						verb, summary = "would add", "would be added"
+					}
+					for _, a := range actions {
+						fmt.Printf("%s: %s\n", verb, a)
+					}
+					fmt.Printf("%d of %d records in %s %s\n", len(actions), len(entries), c.String("file"), summary)
+					return err
+				},
+			},
+
+			// "plan" shows the changes that would bring a domain to its desired state
+			{Name: "plan",
+				Usage: "show the changes that would bring a domain to the desired state in a file",
+				Flags: []cli.Flag{stateFlag},
+				Action: func(c *cli.Context) error {
+					_, err := plan(c)
+					return err
+				},
+			},
+
+			// "apply" makes the changes that "plan" shows
+			{Name: "apply",
+				Usage: "bring a domain to the desired state in a file",
+				Flags: []cli.Flag{stateFlag},
+				Action: func(c *cli.Context) error {
+					actions, err := plan(c)
+					if err != nil || len(actions) == 0 {
+						return err
+					}
+					client, err := getClient(options()...)
+					if err != nil {
+						return err
+					}
+					return client.DoActionsContext(c.Context, actions...)
+				},
+			},
+
 			// "add" adds an action to the Actions stack for each domain, then executes, so
 			// any dependencies such as expanding records don't need to leak out here: just
 			// stack up the actions, let the subsys figure it out.
@@ -97,11 +334,15 @@ func main() {
 						case value == "":
 						case hostpart == "":
 						default:
-							actions = append(actions, hover.NewAction(hover.Add, hostpart+"."+d, d, value, ttl))
+							actions = append(actions, newAction(hover.Add, hostpart+"."+d, d, value))
 						}
 					}
 
-					return getClient(username, password, passfile).DoActions(actions...)
+					client, err := getClient(options()...)
+					if err != nil {
+						return err
+					}
+					return client.DoActionsContext(c.Context, actions...)
 				},
 			},
 
@@ -118,11 +359,15 @@ func main() {
 						case value == "":
 						case hostpart == "":
 						default:
-							actions = append(actions, hover.NewAction(hover.Update, hostpart+"."+d, d, value, ttl))
+							actions = append(actions, newAction(hover.Update, hostpart+"."+d, d, value))
 						}
 					}
 
-					return getClient(username, password, passfile).DoActions(actions...)
+					client, err := getClient(options()...)
+					if err != nil {
+						return err
+					}
+					return client.DoActionsContext(c.Context, actions...)
 				},
 			},
 
@@ -130,19 +375,35 @@ func main() {
 			// "add" above: any dependencies such as expanding records don't need to leak out here: just
 			// stack up the actions, let the subsys figure it out.
 			{Name: "delete",
-				Usage: "delete a record in each domain (extra parm for hostname)",
+				Usage: "delete a record in each domain (extra parm for hostname; value picks one of several)",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "all", Usage: "delete every record matching, rather than refusing to pick one"},
+				},
 				Action: func(c *cli.Context) error {
+					act := hover.Delete
+					if c.Bool("all") {
+						act = hover.DeleteAll
+					}
+					deleteType := "" // any type, unless --type is given: its TXT default is for adds
+					if c.IsSet("type") {
+						deleteType = rrtype
+					}
+
 					actions := make([]hover.Action, 0)
 					fmt.Printf("deleting from %v\n", domains.Value())
 					for _, d := range domains.Value() {
 						switch {
 						case hostpart == "":
 						default:
-							actions = append(actions, hover.NewAction(hover.Delete, hostpart+"."+d, d, "", ttl))
+							actions = append(actions, hover.NewRecordAction(act, hostpart+"."+d, d, deleteType, value, ttl).WithPriority(priority).WithWeight(weight).WithPort(port))
 						}
 					}
 
-					return getClient(username, password, passfile).DoActions(actions...)
+					client, err := getClient(options()...)
+					if err != nil {
+						return err
+					}
+					return client.DoActionsContext(c.Context, actions...)
 				},
 			},
 		},
@@ -152,10 +413,22 @@ func main() {
 			&cli.StringFlag{Name: "passfile", Usage: "username/password file", Destination: &passfile, EnvVars: []string{"HOVER_PASSFILE", "PASSFILE"}},
 			&cli.StringFlag{Name: "password", Usage: "password if not using passfile", Destination: &password, EnvVars: []string{"HOVER_PASSWORD", "PASSWORD"}},
 			&cli.StringFlag{Name: "username", Usage: "username if not using passfile", Destination: &username, EnvVars: []string{"HOVER_USERNAME", "USERNAME"}},
+			&cli.StringFlag{Name: "base-url", Usage: "root of the Hover API, ie a proxy (default " + hover.DefaultBaseURL + ")", Destination: &baseURL, EnvVars: []string{"HOVER_BASE_URL"}},
+			&cli.StringFlag{Name: "totp-secret", Usage: "base32 secret of the 2FA authenticator app, to generate codes rather than prompt (experimental)", Destination: &totp, EnvVars: []string{"HOVER_TOTP_SECRET"}},
+			&cli.StringFlag{Name: "session-file", Usage: "file to keep the login session in between runs (kept 0600)", Destination: &sessions, EnvVars: []string{"HOVER_SESSION_FILE"}},
+			&cli.StringFlag{Name: "snapshot", Usage: "read domains from a snapshot saved by info --save-snapshot, rather than Hover (read-only)", Destination: &snapshot, EnvVars: []string{"HOVER_SNAPSHOT"}},
+			&cli.StringFlag{Name: "owner", Usage: "only change records marked as owned by this name, marking those added by apply; Hover's own records are never changed", Destination: &owner, EnvVars: []string{"HOVER_OWNER"}},
+			&cli.BoolFlag{Name: "adopt", Usage: "with --owner, let apply claim unmarked records named in the desired state", Destination: &adopt},
 			&cli.StringSliceFlag{Name: "domains", Usage: "domain(s) to act upon", Destination: &domains, EnvVars: []string{"HOVER_DOMAINS", "DOMAINS"}},
 			&cli.StringFlag{Name: "host", Usage: `relative hostname  added/deleted (not FQDN, but the host in "host.${domain}")`, Destination: &hostpart},
 			&cli.StringFlag{Name: "value", Usage: "DNS Value (ie TXT record value)", Destination: &value},
-			&cli.UintFlag{Name: "ttl", Usage: "TTL of zone value if added", Value: 300, Destination: &ttl},
+			&cli.StringFlag{Name: "type", Usage: "DNS record type (A, AAAA, CNAME, MX, SRV, CAA, NS, TXT); delete matches any type unless given", Value: hover.TypeTXT, Destination: &rrtype},
+			&cli.UintFlag{Name: "ttl", Usage: "TTL of zone value if added or updated: one of 300, 900, 3600, 14400, 43200, 86400, or 0 for Hover's default", Value: 300, Destination: &ttl},
+			&cli.UintFlag{Name: "priority", Usage: "priority of MX or SRV record", Destination: &priority},
+			&cli.UintFlag{Name: "weight", Usage: "weight of SRV record", Destination: &weight},
+			&cli.UintFlag{Name: "port", Usage: "port of SRV record", Destination: &port},
+			&cli.Float64Flag{Name: "rate-limit", Usage: "requests per second sent to Hover; 0 for no limit", Value: hover.DefaultRateLimit.Rate, Destination: &rate, EnvVars: []string{"HOVER_RATE_LIMIT"}},
+			&cli.IntFlag{Name: "burst", Usage: "requests sent back-to-back before the rate limit applies", Value: hover.DefaultRateLimit.Burst, Destination: &burst},
 		},
 		HelpName: "hoverdns",
 		Name:     "hoverdns",
@@ -4,25 +4,34 @@
 package hoverdnsapi
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	golog "log"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
-	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
 	authHeader = "hoverauth"
+
+	// DefaultBaseURL is the root of Hover's API; https://www.hover.com/api/domains -> DomainList
+	DefaultBaseURL = "https://www.hover.com/api"
+
+	// SecondFactorApp is the 2FA method of an authenticator app, whose codes can be generated from
+	// a TOTP secret
+	SecondFactorApp = "app"
+	// SecondFactorEmail is the 2FA method of a code emailed at each login
+	SecondFactorEmail = "email"
+
+	needSecondFactor = "need_2fa" // login status when a 2FA code is expected; experimental, see loginSecondFactor
 )
 
 var (
-	// https://www.hover.com/api/domains -> DomainList
-	parsedBaseURL = mustParse("https://www.hover.com/api")
+	parsedBaseURL = mustParse(DefaultBaseURL)
 )
 
 func mustParse(aURL string) *url.URL {
@@ -87,13 +96,22 @@ type Domain struct {
 
 // Entry is a single DNS record, such as a single NS, TXT, A, PTR, AAAA record within a zone.
 type Entry struct {
-	CanRevert bool   `json:"can_revert"`
-	Content   string `json:"content"`    // free-form text of verbatim value to store (ie "192.168.0.1" for A-rec)
-	ID        string `json:"id"`         // A unique opaque identifier defined by Hover
-	Default   bool   `json:"is_default"` // seems to track the default @ or "*" record
-	Name      string `json:"name"`       // entry name, or "*" for default
-	TTL       int    `json:"ttl"`        // TimeToLive, seconds
-	Type      string `json:"type"`       // record type: A, MX, PTR, TXT, etc
+	CanRevert bool   `json:"can_revert" yaml:"can_revert,omitempty"`
+	Content   string `json:"content" yaml:"content"`                 // free-form text of verbatim value to store (ie "192.168.0.1" for A-rec)
+	ID        string `json:"id" yaml:"id,omitempty"`                 // A unique opaque identifier defined by Hover
+	Default   bool   `json:"is_default" yaml:"is_default,omitempty"` // seems to track the default @ or "*" record
+	Name      string `json:"name" yaml:"name"`                       // entry name, or "*" for default
+	TTL       int    `json:"ttl" yaml:"ttl,omitempty"`               // TimeToLive, seconds
+	Type      string `json:"type" yaml:"type"`                       // record type: A, MX, PTR, TXT, etc
+}
+
+// loginResponse is the body returned from a login.  If 2FA is enabled on the account, no cookie is
+// set; instead, the Status is "need_2fa", and the Type is how the code is delivered.  That much is
+// what hovertest does, and isn't confirmed against Hover: see loginSecondFactor.
+type loginResponse struct {
+	APIResponse
+	Status string `json:"status,omitempty"`
+	Type   string `json:"type,omitempty"`
 }
 
 // PlaintextAuth is a structure into which the username and password are read from a plaintext
@@ -105,8 +123,9 @@ type Entry struct {
 //
 // For versatility, the intent is to accept JSON, YAML, and even XML if it's trivial to do.
 type PlaintextAuth struct {
-	Username          string `json:"username"`          // username such as 'chickenandpork', exactly as typed in the login form
-	PlaintextPassword string `json:"plaintextpassword"` // password, in plaintext, for login, exactly as typed in the login form
+	Username          string `json:"username"`             // username such as 'chickenandpork', exactly as typed in the login form
+	PlaintextPassword string `json:"plaintextpassword"`    // password, in plaintext, for login, exactly as typed in the login form
+	TOTPSecret        string `json:"totpsecret,omitempty"` // optional base32 secret of the authenticator app, if 2FA is enabled
 }
 
 // The User record in a Domain seems to record additional contact information that augments the
@@ -150,24 +169,39 @@ type DomainList struct {
 }
 
 // Client is the client context for communicating with Hover DNS API; should only need one of these
-// but keeping state isolated to instances rather than global where possible.
+// but keeping state isolated to instances rather than global where possible.  A Client is safe
+// for concurrent use by multiple goroutines, once configured: SetBaseURL isn't guarded.
 type Client struct {
-	HTTPClient *http.Client
-	log        YALI       // Yet Another Logger Interface, NopLogger to discard
-	authCookie string     // intentionally private
-	domains    DomainList // intentionally private
-	Username   string
-	Password   string
+	HTTPClient     *http.Client
+	BaseURL        *url.URL             // root of the API, ie DefaultBaseURL; point at a proxy or fake for testing
+	log            YALI                 // Yet Another Logger Interface, NopLogger to discard
+	authCookie     string               // intentionally private
+	domains        DomainList           // intentionally private
+	domainsFetched time.Time            // when domains was read
+	entriesFetched map[string]time.Time // when the entries of each domain were read
+	cacheTTL       time.Duration        // how long domains and entries are trusted
+	offline        bool                 // served from a snapshot, never contacting Hover
+	userAgent      string               // User-Agent header sent on each request
+	retry          RetryPolicy          // how failed requests are retried
+	limiter        *rateLimiter         // holds back requests to stay within a polite budget
+	totpSecret     string               // base32 secret to generate 2FA codes; intentionally private
+	secondFactor   SecondFactorFunc     // asks for a 2FA code when it can't be generated
+	sessions       SessionStore         // saves logins for reuse, if set
+	ownership      *Ownership           // limits the records changed, if set
+	mu             sync.RWMutex         // guards domains, their fetch times, and authCookie
+	flights        flightGroup          // deduplicates concurrent logins and fills
+	statsMu        sync.Mutex
+	stats          Stats
+	Username       string
+	Password       string
 }
 
-// APIURL is an attempt to keep the URLs all based from parsedBaseURL, but more symbollically
+// APIURL is an attempt to keep the URLs all based from DefaultBaseURL, but more symbollically
 // generated and less risk of typos.  The gain on this function is dubious, and this may disappear
 //
-// TODO: consider rolling in c.BaseURL
+// A Client uses its own BaseURL: see Client.APIURL
 func APIURL(resource string) string {
-	newURL := *parsedBaseURL
-	newURL.Path = fmt.Sprintf("%s/%s", newURL.Path, resource)
-	return newURL.String()
+	return apiURL(parsedBaseURL, resource)
 }
 
 // APIURLDNS extends the consistency objectives of APIURL by bookending a domain unique ID with
@@ -176,31 +210,116 @@ func APIURLDNS(domainID string) string {
 	return APIURL(fmt.Sprintf("domains/%s/dns", domainID))
 }
 
+// APIURLEntry addresses a single DNS entry by its unique ID (ie "dns1234567"), which is how Hover
+// expects an in-place update of a record
+func APIURLEntry(entryID string) string {
+	return APIURL(fmt.Sprintf("dns/%s", entryID))
+}
+
+func apiURL(base *url.URL, resource string) string {
+	newURL := *base
+	newURL.Path = fmt.Sprintf("%s/%s", strings.TrimSuffix(newURL.Path, "/"), resource)
+	return newURL.String()
+}
+
+// baseURL returns the BaseURL of the client, or the DefaultBaseURL if unset
+func (c *Client) baseURL() *url.URL {
+	if c.BaseURL == nil {
+		return parsedBaseURL
+	}
+	return c.BaseURL
+}
+
+// SetBaseURL parses and sets the root of the API that the client talks to, such as a staging or
+// recording proxy, or a hovertest server.  The login cookie is tracked per-URL, so changing the
+// URL implies a fresh login.
+func (c *Client) SetBaseURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("hover: base URL [%s] unparseable: %w", rawURL, err)
+	}
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("hover: base URL [%s] needs a scheme and host", rawURL)
+	}
+	c.BaseURL = parsed
+	return nil
+}
+
+// APIURL is the package-level APIURL relative to the client's BaseURL
+func (c *Client) APIURL(resource string) string {
+	return apiURL(c.baseURL(), resource)
+}
+
+// APIURLDNS is the package-level APIURLDNS relative to the client's BaseURL
+func (c *Client) APIURLDNS(domainID string) string {
+	return c.APIURL(fmt.Sprintf("domains/%s/dns", domainID))
+}
+
+// APIURLEntry is the package-level APIURLEntry relative to the client's BaseURL
+func (c *Client) APIURLEntry(entryID string) string {
+	return c.APIURL(fmt.Sprintf("dns/%s", entryID))
+}
+
 // GetDomainEntries gets the entries for a specific domain -- essentially the zone records
 func (c *Client) GetDomainEntries(domain string) error {
-	if _, err := c.GetAuth(); err != nil {
-		return fmt.Errorf(`Exception "%s" getting auth for [%s]`, err, APIURLDNS(domain))
+	return c.GetDomainEntriesContext(context.Background(), domain)
+}
+
+// GetDomainEntriesContext is GetDomainEntries bound to a context for cancellation and deadlines
+func (c *Client) GetDomainEntriesContext(ctx context.Context, domain string) error {
+	if c.offline {
+		if _, ok := c.GetDomainByName(domain); !ok {
+			return fmt.Errorf("hover: entries of %s: %w", domain, ErrDomainNotFound)
+		}
+		return nil
+	}
+	_, err := c.flights.do("entries:"+domain, func() (interface{}, error) {
+		return nil, c.getDomainEntries(ctx, domain)
+	})
+	return err
+}
+
+// getDomainEntries does the work of GetDomainEntries, which is deduplicated when concurrent
+func (c *Client) getDomainEntries(ctx context.Context, domain string) error {
+	if _, err := c.GetAuthContext(ctx); err != nil {
+		return fmt.Errorf(`Exception getting auth for [%s]: %w`, c.APIURLDNS(domain), err)
 	}
-	resp, err := c.HTTPClient.Get(APIURLDNS(domain))
+	resp, err := c.do(ctx, apiCall{method: http.MethodGet, url: c.APIURLDNS(domain)})
 	if err != nil {
-		c.log.Printf(`Exception "%s" hitting [%s]`, err, APIURLDNS(domain))
-		return fmt.Errorf(`Exception "%s" hitting [%s]`, err, APIURLDNS(domain))
+		c.log.Printf(`Exception "%s" hitting [%s]`, err, c.APIURLDNS(domain))
+		return fmt.Errorf(`Exception hitting [%s]: %w`, c.APIURLDNS(domain), err)
 	} else {
+		defer resp.Body.Close()
+		if err := checkStatus(resp); err != nil {
+			return err
+		}
 		body, _ := ioutil.ReadAll(resp.Body)
 		var nd DomainList
 		json.Unmarshal([]byte(body), &nd)
 
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		found := false
 		for n, v := range c.domains.Domains {
 			if v.DomainName == domain {
 				c.log.Printf(`replacing "%+v"`, c.domains.Domains[n])
 				for _, d := range nd.Domains {
 					if d.DomainName == domain {
 						c.domains.Domains[n] = d
+						if c.entriesFetched == nil {
+							c.entriesFetched = make(map[string]time.Time)
+						}
+						c.entriesFetched[domain] = time.Now()
+						found = true
 					}
 				}
 				c.log.Printf(`replaced "%+v"`, c.domains.Domains[n])
 			}
 		}
+		if !found {
+			return fmt.Errorf("hover: entries of %s: %w", domain, ErrDomainNotFound)
+		}
 		return nil
 	}
 }
@@ -208,49 +327,129 @@ func (c *Client) GetDomainEntries(domain string) error {
 // FillDomains fills the list of domains allocated to the usernamr and password to the Domains
 // structure.  It will use GetAuth() to perform a login if necessary.
 func (c *Client) FillDomains() error {
-	if _, err := c.GetAuth(); err == nil {
-		resp, err := c.HTTPClient.Get(APIURL("domains"))
-		c.log.Printf("Hitting [%s]\n", APIURL("domains"))
+	return c.FillDomainsContext(context.Background())
+}
+
+// FillDomainsContext is FillDomains bound to a context for cancellation and deadlines
+func (c *Client) FillDomainsContext(ctx context.Context) error {
+	if c.offline {
+		return nil
+	}
+	_, err := c.flights.do("domains", func() (interface{}, error) {
+		return nil, c.fillDomains(ctx)
+	})
+	return err
+}
+
+// fillDomains does the work of FillDomains, which is deduplicated when concurrent
+func (c *Client) fillDomains(ctx context.Context) error {
+	if _, err := c.GetAuthContext(ctx); err == nil {
+		resp, err := c.do(ctx, apiCall{method: http.MethodGet, url: c.APIURL("domains")})
+		c.log.Printf("Hitting [%s]\n", c.APIURL("domains"))
 		if err != nil {
-			c.log.Printf("hoverdnsapi: GET of %s threw: [%+v].  Domains not expected to be filled.", APIURL("domains"), err)
-			return fmt.Errorf("hoverdnsapi: GET of %s threw: [%+v].  Domains not expected to be filled", APIURL("domains"), err)
+			c.log.Printf("hoverdnsapi: GET of %s threw: [%+v].  Domains not expected to be filled.", c.APIURL("domains"), err)
+			return fmt.Errorf("hoverdnsapi: GET of %s threw: [%w].  Domains not expected to be filled", c.APIURL("domains"), err)
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode != 200 {
-			c.log.Printf("hover: Info: getting domains as user=%s pass=%s returned non-200: Status: %+v StatusCode: %+v\n", c.Username, c.Password, resp.Status, resp.StatusCode)
-			return fmt.Errorf("hoverdnsapi: GET of %s as user=%s returned non-200 error: Status: %+v StatusCode: %+v", APIURL("domains"), c.Username, resp.Status, resp.StatusCode)
+		if err := checkStatus(resp); err != nil {
+			c.log.Printf("hover: Info: getting domains as user=%s returned non-200: %v\n", c.Username, err)
+			return fmt.Errorf("hoverdnsapi: GET of domains as user=%s: %w", c.Username, err)
 		} else {
-			json.NewDecoder(resp.Body).Decode(&c.domains)
-			//c.log.Printf("hover: getting returned: [%+v]\n", c.domains)
+			var nd DomainList
+			json.NewDecoder(resp.Body).Decode(&nd)
+			//c.log.Printf("hover: getting returned: [%+v]\n", nd)
+
+			c.mu.Lock()
+			c.keepEntries(nd.Domains)
+			c.domains = nd
+			c.domainsFetched = time.Now()
+			c.mu.Unlock()
 		}
 	} else {
-		c.log.Printf("Auth for user=%s at %s failed\n", c.Username, APIURL("domains"))
-		return fmt.Errorf("hoverdnsapi: Auth for GET of %s as user=%s failed", APIURL("domains"), c.Username)
+		c.log.Printf("Auth for user=%s at %s failed\n", c.Username, c.APIURL("domains"))
+		return fmt.Errorf("hoverdnsapi: Auth for GET of %s as user=%s failed: %w", c.APIURL("domains"), c.Username, err)
 	}
 	return nil
 }
 
-// ExistingTXTRecords checks whether the given TXT record exists; err != nil if not found
-func (c *Client) ExistingTXTRecords(fqdn string) error {
-	return fmt.Errorf("hover: (%s) we actually got here: %s", fqdn, c.authCookie)
+// ExistingTXTRecords returns the TXT entries of the FQDN, looked up in whichever of the account's
+// domains holds it.  No such entries is an empty list, not an error; an FQDN in none of the
+// domains is ErrDomainNotFound.
+func (c *Client) ExistingTXTRecords(fqdn string) ([]Entry, error) {
+	return c.ExistingTXTRecordsContext(context.Background(), fqdn)
+}
+
+// ExistingTXTRecordsContext is ExistingTXTRecords bound to a context for cancellation and deadlines
+func (c *Client) ExistingTXTRecordsContext(ctx context.Context, fqdn string) ([]Entry, error) {
+	return c.ExistingRecordsContext(ctx, fqdn, TypeTXT)
+}
+
+// ExistingRecords returns the entries of the FQDN of the given type, or of any type if rrtype is
+// blank, as ExistingTXTRecords does for TXT.  The domain's entries are read from Hover if not
+// already cached.
+func (c *Client) ExistingRecords(fqdn, rrtype string) ([]Entry, error) {
+	return c.ExistingRecordsContext(context.Background(), fqdn, rrtype)
+}
+
+// ExistingRecordsContext is ExistingRecords bound to a context for cancellation and deadlines
+func (c *Client) ExistingRecordsContext(ctx context.Context, fqdn, rrtype string) ([]Entry, error) {
+	domains, err := c.ListDomainsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := domainOf(domains, fqdn)
+	if !ok {
+		return nil, fmt.Errorf("hover: no domain holds %s: %w", fqdn, ErrDomainNotFound)
+	}
+	return c.ListRecordsContext(ctx, d.DomainName, RecordFilter{Name: fqdn, Type: rrtype})
+}
+
+// domainOf finds the domain that holds the FQDN; the longest match wins, should the account hold
+// both a domain and one of its subdomains
+func domainOf(domains []Domain, fqdn string) (Domain, bool) {
+	var found Domain
+	for _, d := range domains {
+		if _, ok := d.hostname(fqdn); ok && len(d.DomainName) > len(found.DomainName) {
+			found = d
+		}
+	}
+	return found, found.DomainName != ""
 }
 
 // GetAuth returns the authentication key for the username and password, performing a login if the
 // key is not already known from a previous login.
 func (c *Client) GetAuth() (string, error) {
+	return c.GetAuthContext(context.Background())
+}
+
+// GetAuthContext is GetAuth bound to a context for cancellation and deadlines
+func (c *Client) GetAuthContext(ctx context.Context) (string, error) {
 	if auth, ok := c.GetCookie(authHeader); ok {
 		return auth, nil
 	}
 
-	c.log.Printf("Getting fresh authCookie for user=%s at %s\n", c.Username, APIURL("login"))
-	req, _ := http.NewRequest("POST", APIURL("login"), strings.NewReader(url.Values{
+	auth, err := c.flights.do("login", func() (interface{}, error) {
+		return c.login(ctx)
+	})
+	if err != nil {
+		return "", err
+	}
+	return auth.(string), nil
+}
+
+// login restores a saved session, or logs in afresh; concurrent logins are deduplicated by
+// GetAuth
+func (c *Client) login(ctx context.Context) (string, error) {
+	if auth, ok, err := c.restoreSession(ctx); err != nil || ok {
+		return auth, err
+	}
+
+	c.log.Printf("Getting fresh authCookie for user=%s at %s\n", c.Username, c.APIURL("login"))
+	resp, err := c.do(ctx, apiCall{method: http.MethodPost, url: c.APIURL("login"), idempotent: true, noReauth: true, values: url.Values{
 		"username": {c.Username},
 		"password": {c.Password},
-	}.Encode()))
-
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := c.HTTPClient.Do(req)
+	}})
 	if err != nil {
 		c.log.Printf("Error while executing POST: %v", err)
 		return "", err
@@ -258,12 +457,74 @@ func (c *Client) GetAuth() (string, error) {
 	defer resp.Body.Close()
 
 	body, _ := ioutil.ReadAll(resp.Body)
-	c.log.Println(string(body))
 	if auth, ok := c.GetCookie(authHeader); ok {
-		c.log.Printf("Auth found for user=%s at %s\n", c.Username, APIURL("login"))
+		c.log.Printf("Auth found for user=%s at %s\n", c.Username, c.APIURL("login"))
+		c.saveSession(resp, auth)
 		return auth, nil
 	}
-	return "", fmt.Errorf("hover: No auth in response: %+v -> %s", c.HTTPClient, body)
+
+	var challenge loginResponse
+	if json.Unmarshal(body, &challenge) == nil && challenge.Status == needSecondFactor {
+		return c.loginSecondFactor(ctx, challenge.Type)
+	}
+
+	// Either refused outright, or a 200 without the cookie we need: both are failed logins
+	he := newHTTPError(resp, body)
+	he.Err = ErrAuthFailed
+	return "", he
+}
+
+// loginSecondFactor completes a login that Hover challenged for a 2FA code, delivered by the
+// given method ("app" or "email").
+//
+// EXPERIMENTAL: Hover doesn't document its sign-in, and I haven't been able to confirm this flow
+// (the "need_2fa" status, and the code POSTed to login/2fa) against hover.com; it's only known to
+// match hovertest.  If your 2FA login fails, please PR what Hover actually does.
+func (c *Client) loginSecondFactor(ctx context.Context, method string) (string, error) {
+	code, err := c.secondFactorCode(ctx, method)
+	if err != nil {
+		return "", err
+	}
+
+	c.log.Printf("Sending %s 2FA code for user=%s at %s\n", method, c.Username, c.APIURL("login/2fa"))
+	resp, err := c.do(ctx, apiCall{method: http.MethodPost, url: c.APIURL("login/2fa"), noReauth: true, values: url.Values{
+		"code": {code},
+	}})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	if auth, ok := c.GetCookie(authHeader); ok {
+		c.log.Printf("Auth found for user=%s after 2FA\n", c.Username)
+		c.saveSession(resp, auth)
+		return auth, nil
+	}
+
+	he := newHTTPError(resp, body)
+	he.Err = ErrAuthFailed
+	return "", he
+}
+
+// secondFactorCode generates a TOTP code if the secret is known and the code is expected from
+// an app, otherwise asks the prompt
+func (c *Client) secondFactorCode(ctx context.Context, method string) (string, error) {
+	if c.totpSecret != "" && (method == "" || method == SecondFactorApp) {
+		return TOTPCode(c.totpSecret, time.Now())
+	}
+	if c.secondFactor == nil {
+		return "", fmt.Errorf("hover: login of %s needs a %s code: %w", c.Username, method, ErrSecondFactorRequired)
+	}
+
+	code, err := c.secondFactor(ctx, method)
+	if err != nil {
+		return "", fmt.Errorf("hover: login of %s: %v: %w", c.Username, err, ErrSecondFactorRequired)
+	}
+	if code = strings.TrimSpace(code); code == "" {
+		return "", fmt.Errorf("hover: login of %s: empty %s code: %w", c.Username, method, ErrSecondFactorRequired)
+	}
+	return code, nil
 }
 
 // GetCookie searches existing cookies from a login to Hover's API to find the given cookie.
@@ -274,15 +535,16 @@ func (c *Client) GetCookie(key string) (value string, ok bool) {
 	if c.HTTPClient.Jar == nil {
 		return "", false
 	}
-	if 1 > len(c.HTTPClient.Jar.Cookies(parsedBaseURL)) {
-		c.log.Printf("no cookies for %s", parsedBaseURL)
+	cookies := c.HTTPClient.Jar.Cookies(c.baseURL())
+	if 1 > len(cookies) {
+		c.log.Printf("no cookies for %s", c.baseURL())
 		return "", false
 	}
-	c.log.Printf("breaking apart cookies for %+v\n", parsedBaseURL)
-	for _, v := range c.HTTPClient.Jar.Cookies(parsedBaseURL) {
-		c.log.Printf("k/v: %s/%s\n", v.Name, v.Value)
+	c.log.Printf("breaking apart cookies for %+v\n", c.baseURL())
+	for _, v := range cookies {
+		c.log.Printf("cookie: %s\n", v.Name) // never the value: the auth cookie is as good as a password
 		if v.Name == key {
-			c.log.Printf("returning found: k/v: %s/%s\n", v.Name, v.Value)
+			c.log.Printf("returning found: %s\n", v.Name)
 			return v.Value, true
 		}
 	}
@@ -291,10 +553,15 @@ func (c *Client) GetCookie(key string) (value string, ok bool) {
 	return "", false
 }
 
-// GetDomainByName searches iteratively and returns the Domain record that has the given name
+// GetDomainByName searches iteratively and returns the Domain record that has the given name.
+// The Domain is a copy, entries included, so it can be used without affecting the client.
 func (c *Client) GetDomainByName(domainname string) (*Domain, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
 	for _, v := range c.domains.Domains {
 		if v.DomainName == domainname {
+			v.Entries = append([]Entry(nil), v.Entries...)
 			return &v, true
 		} else {
 			c.log.Printf(`Domain "%s" is not objective "%s"\n`, v.DomainName, domainname)
@@ -304,74 +571,203 @@ func (c *Client) GetDomainByName(domainname string) (*Domain, bool) {
 	return nil, false
 }
 
-// Upsert inserts or updates a TXT record using the specified parameters
+// ListDomains returns the domains of the account, read from Hover if not already cached.  The
+// entries of each domain are only included if cached; use ListRecords to read them.
+func (c *Client) ListDomains() ([]Domain, error) {
+	return c.ListDomainsContext(context.Background())
+}
+
+// ListDomainsContext is ListDomains bound to a context for cancellation and deadlines
+func (c *Client) ListDomainsContext(ctx context.Context) ([]Domain, error) {
+	if !c.domainsFresh() {
+		if err := c.FillDomainsContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return copyDomains(c.domains.Domains), nil
+}
+
+// GetDomain returns a single domain by its name (ie "example.com") or Hover's ID (ie "dom123"),
+// read from Hover if not already cached.  A domain missing from the cache is looked for once more
+// in a fresh list, in case it was added since; if still missing, it's ErrDomainNotFound.
+func (c *Client) GetDomain(nameOrID string) (Domain, error) {
+	return c.GetDomainContext(context.Background(), nameOrID)
+}
+
+// GetDomainContext is GetDomain bound to a context for cancellation and deadlines
+func (c *Client) GetDomainContext(ctx context.Context, nameOrID string) (Domain, error) {
+	cached := c.domainsFresh()
+	domains, err := c.ListDomainsContext(ctx)
+	if err != nil {
+		return Domain{}, err
+	}
+	if d, ok := lookupDomain(domains, nameOrID); ok {
+		return d, nil
+	}
+
+	if cached { // the cache may predate the domain
+		if err := c.FillDomainsContext(ctx); err != nil {
+			return Domain{}, err
+		}
+		if domains, err = c.ListDomainsContext(ctx); err != nil {
+			return Domain{}, err
+		}
+		if d, ok := lookupDomain(domains, nameOrID); ok {
+			return d, nil
+		}
+	}
+	return Domain{}, fmt.Errorf("hover: domain %s: %w", nameOrID, ErrDomainNotFound)
+}
+
+// lookupDomain finds the domain by its name or ID
+func lookupDomain(domains []Domain, nameOrID string) (Domain, bool) {
+	name := strings.TrimSuffix(nameOrID, ".")
+	for _, d := range domains {
+		if strings.EqualFold(d.DomainName, name) || d.ID == nameOrID {
+			return d, true
+		}
+	}
+	return Domain{}, false
+}
+
+// Upsert inserts or updates a TXT record using the specified parameters: the FQDN's TXT record is
+// updated if it has one, or added if not.  Nothing is changed if it already holds the value (and
+// TTL, if given).  An FQDN with several TXT records is ambiguous; see UpsertRecord to replace them.
 func (c *Client) Upsert(fqdn, domain, value string, ttl uint) error {
+	return c.UpsertContext(context.Background(), fqdn, domain, value, ttl)
+}
 
-	actions := []Action{}
-	if err := c.ExistingTXTRecords(fqdn); err == nil {
-		actions = append(actions, Action{action: Update, domain: domain, fqdn: fqdn, value: value, ttl: ttl})
-	} else {
-		actions = append(actions, Action{action: Add, domain: domain, fqdn: fqdn, value: value, ttl: ttl})
+// UpsertContext is Upsert bound to a context for cancellation and deadlines
+func (c *Client) UpsertContext(ctx context.Context, fqdn, domain, value string, ttl uint) error {
+	existing, err := c.ExistingTXTRecordsContext(ctx, fqdn)
+	if err != nil {
+		return fmt.Errorf("hover: failed to look up record(s) for %s: %w", domain, err)
 	}
 
-	if err := c.DoActions(actions...); err != nil {
+	action := NewAction(Add, fqdn, domain, value, ttl)
+	for _, e := range existing {
+		if e.Content == action.Content() && (ttl == 0 || uint(e.TTL) == ttl) {
+			c.log.Printf(`TXT "%s" already holds "%s"; nothing to do`, fqdn, value)
+			return nil
+		}
+	}
+	if len(existing) > 0 {
+		action = NewAction(Update, fqdn, domain, value, ttl)
+	}
+
+	if err := c.DoActionsContext(ctx, action); err != nil {
 		return fmt.Errorf("hover: failed to add record(s) for %s: %w", domain, err)
 	}
 
 	return nil
 }
 
-// Delete merely enqueues a delete action for DoActions to process
+// Delete merely enqueues a delete action for DoActions to process: the record of the FQDN, of any
+// type, is deleted.
+//
+// Since names can be shared, Delete no longer picks one of several records at random: if more
+// than one record has the name, such as the TXT records of an ACME validation of both a wildcard
+// and the apex, nothing is deleted and the error is ErrAmbiguous.  Use DeleteValue to delete the
+// one holding a given value, or DoActions with DeleteAll to delete them all.
 func (c *Client) Delete(fqdn, domain string) error {
+	return c.DeleteContext(context.Background(), fqdn, domain)
+}
+
+// DeleteContext is Delete bound to a context for cancellation and deadlines
+func (c *Client) DeleteContext(ctx context.Context, fqdn, domain string) error {
 	c.log.Printf(`deleting fqdn "%s" from domain "%s"`, fqdn, domain)
-	if err := c.DoActions(Action{action: Delete, fqdn: fqdn, domain: domain}); err != nil {
+	if err := c.DoActionsContext(ctx, Action{action: Delete, fqdn: fqdn, domain: domain}); err != nil {
 		return fmt.Errorf("hover: failed to delete record for %s: %w", domain, err)
 	}
 
 	return nil
 }
 
-// HTTPDelete actually does an HTTP call with the DELETE method.  BOG-standard Go only offers GET
-// and POST.
-//
-// TODO: move to a separate file as a layer onto net/http
-func (c *Client) HTTPDelete(url string) (err error) {
-	req, err := http.NewRequest(http.MethodDelete, url, nil)
-	if err != nil {
-		return fmt.Errorf("HTTPDelete: creating new request: %w", err)
-	}
-
-	//req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", d.token))
+// DeleteValue deletes the TXT record of the FQDN that holds the value, leaving any others of the
+// name alone: the way to clean up one ACME challenge of several.  Like Upsert, it deals in TXT
+// records; a value that no record holds is nothing to do.
+func (c *Client) DeleteValue(fqdn, domain, value string) error {
+	return c.DeleteValueContext(context.Background(), fqdn, domain, value)
+}
 
-	resp, err := c.HTTPClient.Do(req)
-	defer func() { _ = resp.Body.Close() }()
-	if err != nil {
-		return fmt.Errorf("HTTPDelete: executing delete request: %w", err)
+// DeleteValueContext is DeleteValue bound to a context for cancellation and deadlines
+func (c *Client) DeleteValueContext(ctx context.Context, fqdn, domain, value string) error {
+	c.log.Printf(`deleting TXT "%s" of fqdn "%s" from domain "%s"`, value, fqdn, domain)
+	if err := c.DoActionsContext(ctx, NewAction(Delete, fqdn, domain, value, 0)); err != nil {
+		return fmt.Errorf("hover: failed to delete record for %s: %w", domain, err)
 	}
+
 	return nil
 }
 
-// HTTPUpdate actually does an HTTP call with the PUT method.  BOG-standard Go only offers GET
-// and POST.
-//
-// # update an existing DNS record: client.call("put", "dns/dns1234567", {"content": "127.0.0.1"})
-//
-// TODO: I just couldn't get this to work properly: every attempt was 422: Unactionable.  Replaced by delete/add
-
 // GetEntryByFQDN attempts to find a single Entry in the Domain, returning a non-nil result if
 // found.  "ok" is manipulated so that an "if" can be used to check whether it was found without
 // having to rely on sentinel or implicit values of the returned (ie a nil Entry might not always
 // mean "not found", but it does today)
 func (d Domain) GetEntryByFQDN(fqdn string) (e *Entry, ok bool) {
-	if !strings.HasSuffix(fqdn, "."+d.DomainName) {
+	return d.GetEntryByFQDNAndType(fqdn, "")
+}
+
+// FindEntries returns every entry of the FQDN within the domain, narrowed to the type and content
+// if they're given (non-blank).  Names can be shared, such as by round-robin A records, or by the
+// several TXT records of an ACME validation of both a wildcard and the apex.
+func (d Domain) FindEntries(fqdn, rrtype, content string) []Entry {
+	entries := make([]Entry, 0)
+	hostname, ok := d.hostname(fqdn)
+	if !ok {
+		return entries
+	}
+
+	for _, e := range d.Entries {
+		if e.Name == hostname && (rrtype == "" || strings.EqualFold(e.Type, rrtype)) && (content == "" || e.Content == content) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// findEntry finds the entry of exactly the given FQDN, type, and content
+func (d Domain) findEntry(fqdn, rrtype, content string) (*Entry, bool) {
+	hostname, ok := d.hostname(fqdn)
+	if !ok {
 		return nil, false
 	}
 
-	hostname := fqdn[0:len(fqdn)-len(d.DomainName)-1] + ""
-	fmt.Printf("searching for [%s] (ie [%s]) in [%s]\n", hostname, fmt.Sprintf("%s.%s", hostname, d.DomainName), d.DomainName)
+	for _, e := range d.Entries {
+		if e.Name == hostname && strings.EqualFold(e.Type, rrtype) && e.Content == content {
+			return &e, true
+		}
+	}
+	return nil, false
+}
+
+// hostname maps an FQDN within the domain to the name used in its entries; the domain itself is
+// "@"
+func (d Domain) hostname(fqdn string) (string, bool) {
+	fqdn = strings.TrimSuffix(fqdn, ".")
+	if strings.EqualFold(fqdn, d.DomainName) {
+		return "@", true
+	}
+	if !strings.HasSuffix(fqdn, "."+d.DomainName) {
+		return "", false
+	}
+	return fqdn[0 : len(fqdn)-len(d.DomainName)-1], true
+}
+
+// GetEntryByFQDNAndType is GetEntryByFQDN narrowed to a single record type (ie "A", "TXT");
+// a blank rrtype matches any type.
+func (d Domain) GetEntryByFQDNAndType(fqdn, rrtype string) (e *Entry, ok bool) {
+	hostname, ok := d.hostname(fqdn)
+	if !ok {
+		return nil, false
+	}
 
 	for _, e := range d.Entries {
-		if e.Name == hostname {
+		if e.Name == hostname && (rrtype == "" || strings.EqualFold(e.Type, rrtype)) {
 			return &e, true
 		}
 	}
@@ -380,35 +776,33 @@ func (d Domain) GetEntryByFQDN(fqdn string) (e *Entry, ok bool) {
 
 // NewClient Creates a Hover client using plaintext passwords against plain username.
 // Consider the risk of where the text is stored.
+//
+// Besides a YALI logger, any Option can be given in opt.  Failures are only logged: if the
+// passfile can't be read, the given username/password are used instead.
+//
+// Deprecated: use New, which reports a bad option or an unreadable passfile as an error.
 func NewClient(username, password, filename string, timeout time.Duration, opt ...interface{}) *Client {
-	j, _ := cookiejar.New(nil)
-	var defaultLogger YALI = golog.New(os.Stderr, "", golog.LstdFlags)
+	c := defaultClient()
+	c.HTTPClient.Timeout = timeout
+	c.Username = username
+	c.Password = password
 
 	for _, vv := range opt {
 		switch v := vv.(type) {
 		case YALI:
-			defaultLogger = v
+			c.log = v
+		case Option:
+			if err := v(c); err != nil {
+				c.log.Printf("hover: ignoring option: %v", err)
+			}
 		}
 	}
 
 	if filename != "" {
-		if observed, err := ReadConfigFile(filename); err == nil {
-			username = observed.Username
-			password = observed.PlaintextPassword
+		if err := WithCredentialsFile(filename)(c); err != nil {
+			c.log.Printf("hover: ignoring passfile: %v", err)
 		}
 	}
-	fmt.Printf("logging in: u: %+v p: %+v f:%+v\n", username, password, filename)
 
-	return &Client{
-		HTTPClient: &http.Client{
-			Jar:     j,
-			Timeout: timeout,
-		},
-		//BaseURL:    "https://www.hover.com/api/login",
-		//Cookie: blank
-		//Domains: make(map[string]string, 2),
-		Username: username,
-		Password: password,
-		log:      defaultLogger,
-	}
+	return c
 }
@@ -1,6 +1,8 @@
 package hoverdnsapi_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -56,6 +58,34 @@ func TestAPIURL(t *testing.T) {
 	}
 }
 
+// TestClientAPIURL checks that a Client builds its URLs from its own BaseURL, leaving the
+// package-level APIURL (and other clients) untouched
+func TestClientAPIURL(t *testing.T) {
+	var tests = []struct {
+		desc     string
+		baseURL  string
+		expected string
+	}{
+		{"default", hoverdnsapi.DefaultBaseURL, "https://www.hover.com/api/domains/12345/dns"},
+		{"local", "http://127.0.0.1:8080/api", "http://127.0.0.1:8080/api/domains/12345/dns"},
+		{"trailing slash", "http://127.0.0.1:8080/api/", "http://127.0.0.1:8080/api/domains/12345/dns"},
+		{"proxy prefix", "https://proxy.example.com/hover/api", "https://proxy.example.com/hover/api/domains/12345/dns"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			client := hoverdnsapi.NewClient("scott", "tiger", "", 90*time.Second, &hoverdnsapi.NopLogger{})
+			if assert.NoError(t, client.SetBaseURL(test.baseURL)) {
+				assert.Equal(t, test.expected, client.APIURLDNS("12345"))
+			}
+			assert.Equal(t, "https://www.hover.com/api/domains/12345/dns", hoverdnsapi.APIURLDNS("12345"))
+		})
+	}
+
+	client := hoverdnsapi.NewClient("scott", "tiger", "", 90*time.Second, &hoverdnsapi.NopLogger{})
+	assert.Error(t, client.SetBaseURL("not a url"))
+}
+
 // TestParseAddress is a framework to test any problematic addresses; I've loaded it with just Hover's address for basic parse-testing
 func TestParseAddress(t *testing.T) {
 	var tests = []struct {
@@ -374,3 +404,18 @@ func TestNewClient(t *testing.T) {
 		})
 	}
 }
+
+// TestCancelledContext confirms that a cancelled context stops the network methods before any
+// request reaches Hover, and that the cancellation can be recognized by the caller
+func TestCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := hoverdnsapi.NewClient("scott", "tiger", "", 90*time.Second, &hoverdnsapi.NopLogger{})
+
+	err := client.FillDomainsContext(ctx)
+	assert.Truef(t, errors.Is(err, context.Canceled), "FillDomainsContext: expected context.Canceled, got %v", err)
+
+	err = client.DoActionsContext(ctx, hoverdnsapi.NewAction(hoverdnsapi.Add, "test1.example.com", "example.com", "ABCDE", 300))
+	assert.Truef(t, errors.Is(err, context.Canceled), "DoActionsContext: expected context.Canceled, got %v", err)
+}
@@ -0,0 +1,211 @@
+package hoverdnsapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sentinel errors that failures from this package can be tested against using errors.Is().  The
+// errors actually returned carry more detail (the domain, the FQDN, or an *HTTPError with the
+// status and payload of Hover's response), but wrap one of these to allow a caller to react to a
+// class of failure without string-matching.
+var (
+	// ErrAuthFailed indicates that Hover refused the username/password, or that a session was
+	// rejected
+	ErrAuthFailed = errors.New("hover: authentication failed")
+	// ErrSecondFactorRequired indicates that the account has 2FA enabled, but no code could be
+	// given: neither a TOTP secret nor a prompt was configured, or the prompt failed
+	ErrSecondFactorRequired = errors.New("hover: second factor required")
+	// ErrOffline indicates that Hover was needed by a client running from a snapshot
+	ErrOffline = errors.New("hover: offline")
+	// ErrDomainNotFound indicates that a domain isn't among those managed by the account
+	ErrDomainNotFound = errors.New("hover: domain not found")
+	// ErrRecordNotFound indicates that no DNS entry matched within a domain
+	ErrRecordNotFound = errors.New("hover: record not found")
+	// ErrAmbiguous indicates that several records matched where only one was expected, such as
+	// a Delete of a name with round-robin A records
+	ErrAmbiguous = errors.New("hover: several records match")
+	// ErrNotOwned indicates that a record couldn't be changed or deleted because the client's
+	// Ownership doesn't allow it, such as a record that Hover manages
+	ErrNotOwned = errors.New("hover: record not owned")
+	// ErrZoneSyntax indicates that a zone file couldn't be read, such as a malformed record, or
+	// one that Hover can't hold
+	ErrZoneSyntax = errors.New("hover: bad zone file")
+	// ErrRateLimited indicates that Hover responded 429 Too Many Requests
+	ErrRateLimited = errors.New("hover: rate limited")
+	// ErrUnactionable indicates that Hover responded 422 Unprocessable Entity ("Unactionable")
+	ErrUnactionable = errors.New("hover: unactionable request")
+	// ErrRejected indicates that Hover responded with any non-2xx status; every *HTTPError
+	// matches this
+	ErrRejected = errors.New("hover: request rejected")
+)
+
+// APIResponse is the envelope Hover wraps around its JSON responses.  On failure, the ErrorCode
+// and Error tend to describe why.
+type APIResponse struct {
+	Succeeded bool   `json:"succeeded"`
+	ErrorCode string `json:"error_code,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// HTTPError describes a response from Hover that was refused: the request made, the status
+// returned, and the payload that came back with it.
+type HTTPError struct {
+	Method     string      // HTTP method of the request, ie "PUT"
+	URL        string      // URL of the request
+	StatusCode int         // numeric status, ie 422
+	Status     string      // status line, ie "422 Unprocessable Entity"
+	Body       string      // raw body of the response
+	Response   APIResponse // body of the response, if it parsed as Hover's JSON envelope
+	Err        error       // optional sentinel that this failure also represents, ie ErrAuthFailed
+
+	RetryAfter time.Duration // how long Hover asked us to hold off (Retry-After on a 429 or 503), if it did
+}
+
+func (e *HTTPError) Error() string {
+	reason := e.Body
+	if e.Response.Error != "" {
+		reason = e.Response.Error
+	}
+	msg := fmt.Sprintf("%s %s returned %s: %s", e.Method, e.URL, e.Status, reason)
+	if e.Err != nil {
+		return fmt.Sprintf("%v: %s", e.Err, msg)
+	}
+	return "hover: " + msg
+}
+
+// Is maps the status of the response onto the sentinel errors so that errors.Is() works without
+// the caller needing to inspect the StatusCode
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrRejected:
+		return true
+	case ErrAuthFailed:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrUnactionable:
+		return e.StatusCode == http.StatusUnprocessableEntity
+	}
+	return false
+}
+
+// Unwrap exposes the optional sentinel in Err
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// newHTTPError creates an *HTTPError from a response whose body has already been read
+func newHTTPError(resp *http.Response, body []byte) *HTTPError {
+	e := &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(body),
+	}
+	if resp.Request != nil {
+		e.Method = resp.Request.Method
+		e.URL = resp.Request.URL.String()
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		e.RetryAfter = retryAfter(resp, time.Now())
+	}
+	_ = json.Unmarshal(body, &e.Response)
+
+	return e
+}
+
+// checkStatus returns an *HTTPError if the response is not a 2xx; the body is consumed in that
+// case so that the reason can be reported.
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	return newHTTPError(resp, body)
+}
+
+// readResponse extends checkStatus to also refuse a 2xx whose JSON body reports
+// "succeeded": false, as Hover does for some failures.  The body is consumed and returned.
+func readResponse(resp *http.Response) ([]byte, error) {
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope struct {
+		Succeeded *bool `json:"succeeded"`
+	}
+	if json.Unmarshal(body, &envelope) == nil && envelope.Succeeded != nil && !*envelope.Succeeded {
+		he := newHTTPError(resp, body)
+		he.Err = ErrRejected
+		return nil, he
+	}
+	return body, nil
+}
+
+// ActionsError is returned by DoActions when one or more actions failed.  Results holds the
+// outcome of every action, in order, so that a caller can see both what failed and what
+// succeeded.
+type ActionsError struct {
+	Results []ActionResult
+}
+
+// Failed returns only the results of the actions that failed
+func (e *ActionsError) Failed() []ActionResult {
+	failed := make([]ActionResult, 0)
+	for _, r := range e.Results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+func (e *ActionsError) Error() string {
+	failed := e.Failed()
+	msgs := make([]string, 0, len(failed))
+	for _, r := range failed {
+		msgs = append(msgs, fmt.Sprintf("%s %s %s: %v", r.Action.action, r.Action.rrtype, r.Action.fqdn, r.Err))
+	}
+	return fmt.Sprintf("hover: %d of %d actions failed: %s", len(failed), len(e.Results), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of each failed action
+func (e *ActionsError) Unwrap() []error {
+	errs := make([]error, 0)
+	for _, r := range e.Failed() {
+		errs = append(errs, r.Err)
+	}
+	return errs
+}
+
+// Is reports whether any failed action's error matches the target.  This mirrors what
+// Unwrap() []error offers in newer Go releases.
+func (e *ActionsError) Is(target error) bool {
+	for _, err := range e.Unwrap() {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first failed action's error that matches the target, as per errors.As()
+func (e *ActionsError) As(target interface{}) bool {
+	for _, err := range e.Unwrap() {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package hoverdnsapi_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"testing"
+
+	"github.com/chickenandpork/hoverdnsapi" // to ensure testing without extra access
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHTTPErrorIs checks that each status maps onto the expected sentinel errors, even when the
+// *HTTPError is wrapped further by the caller
+func TestHTTPErrorIs(t *testing.T) {
+	var tests = []struct {
+		desc     string
+		err      *hoverdnsapi.HTTPError
+		sentinel error
+		expected bool
+	}{
+		{"401 is auth", &hoverdnsapi.HTTPError{StatusCode: http.StatusUnauthorized}, hoverdnsapi.ErrAuthFailed, true},
+		{"403 is auth", &hoverdnsapi.HTTPError{StatusCode: http.StatusForbidden}, hoverdnsapi.ErrAuthFailed, true},
+		{"200 w/o cookie is auth", &hoverdnsapi.HTTPError{StatusCode: http.StatusOK, Err: hoverdnsapi.ErrAuthFailed}, hoverdnsapi.ErrAuthFailed, true},
+		{"429 is rate-limited", &hoverdnsapi.HTTPError{StatusCode: http.StatusTooManyRequests}, hoverdnsapi.ErrRateLimited, true},
+		{"422 is unactionable", &hoverdnsapi.HTTPError{StatusCode: http.StatusUnprocessableEntity}, hoverdnsapi.ErrUnactionable, true},
+		{"500 is rejected", &hoverdnsapi.HTTPError{StatusCode: http.StatusInternalServerError}, hoverdnsapi.ErrRejected, true},
+		{"500 is not auth", &hoverdnsapi.HTTPError{StatusCode: http.StatusInternalServerError}, hoverdnsapi.ErrAuthFailed, false},
+		{"422 is not not-found", &hoverdnsapi.HTTPError{StatusCode: http.StatusUnprocessableEntity}, hoverdnsapi.ErrRecordNotFound, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			wrapped := fmt.Errorf("wrapped: %w", test.err)
+			assert.Equal(t, test.expected, errors.Is(wrapped, test.sentinel))
+
+			var he *hoverdnsapi.HTTPError
+			if assert.True(t, errors.As(wrapped, &he)) {
+				assert.Equal(t, test.err.StatusCode, he.StatusCode)
+			}
+		})
+	}
+}
+
+// TestActionsError checks that the multi-error from DoActions reports only the failed actions,
+// and that each of their errors can still be found with errors.Is() and errors.As()
+func TestActionsError(t *testing.T) {
+	rejected := &hoverdnsapi.HTTPError{Method: "POST", StatusCode: http.StatusUnprocessableEntity, Status: "422 Unprocessable Entity"}
+	err := error(&hoverdnsapi.ActionsError{Results: []hoverdnsapi.ActionResult{
+		{Action: hoverdnsapi.NewAction(hoverdnsapi.Add, "ok.example.com", "example.com", "ABCDE", 300)},
+		{Action: hoverdnsapi.NewAction(hoverdnsapi.Add, "bad.example.com", "example.com", "ABCDE", 300), Err: rejected},
+		{Action: hoverdnsapi.NewAction(hoverdnsapi.Delete, "gone.example.org", "example.org", "", 300), Err: fmt.Errorf("nope: %w", hoverdnsapi.ErrDomainNotFound)},
+	}})
+
+	var ae *hoverdnsapi.ActionsError
+	if assert.True(t, errors.As(err, &ae)) {
+		assert.Len(t, ae.Failed(), 2)
+	}
+	assert.Contains(t, err.Error(), "2 of 3 actions failed")
+	assert.Contains(t, err.Error(), "bad.example.com")
+	assert.NotContains(t, err.Error(), "ok.example.com")
+
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrUnactionable))
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrDomainNotFound))
+	assert.False(t, errors.Is(err, hoverdnsapi.ErrAuthFailed))
+
+	var he *hoverdnsapi.HTTPError
+	if assert.True(t, errors.As(err, &he)) {
+		assert.Equal(t, http.StatusUnprocessableEntity, he.StatusCode)
+	}
+}
@@ -13,4 +13,5 @@ require (
 	github.com/stretchr/testify v1.5.1
 	github.com/urfave/cli v1.22.1
 	github.com/urfave/cli/v2 v2.2.0
+	gopkg.in/yaml.v2 v2.2.2
 )
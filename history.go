@@ -0,0 +1,270 @@
+package hoverdnsapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	canonicaljson "github.com/gibson042/canonicaljson-go"
+)
+
+// HistoryCommit is the outcome of CommitHistory
+type HistoryCommit struct {
+	Changed bool   // false if nothing changed since the last snapshot, so nothing was committed
+	Message string // the commit message, summarizing what changed
+}
+
+// CommitHistory keeps the history of the account in a git repository, as Rancid did for routers:
+// every domain, with its entries, is read from Hover, written into the directory, and committed
+// with a message summarizing what changed since the last commit.  Each domain is written as
+// <domain>.json, in canonical JSON (keys sorted, entries in a stable order) so that nothing
+// changes unless Hover does, and as <domain>.zone, a BIND zone file; the files of domains no
+// longer in the account are removed, and other files are left alone and uncommitted.
+//
+// The directory is made a git repository if it isn't already within one, and git is run from
+// the PATH.  Commits are by the user git is configured with, or "hoverdns" if none is.
+func (c *Client) CommitHistory(dir string) (HistoryCommit, error) {
+	return c.CommitHistoryContext(context.Background(), dir)
+}
+
+// CommitHistoryContext is CommitHistory bound to a context for cancellation and deadlines
+func (c *Client) CommitHistoryContext(ctx context.Context, dir string) (HistoryCommit, error) {
+	if err := c.RefreshContext(ctx); err != nil {
+		return HistoryCommit{}, err
+	}
+	snapshot := c.Snapshot()
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return HistoryCommit{}, fmt.Errorf("hover: history: %w", err)
+	}
+	git := gitRunner{ctx: ctx, dir: dir}
+	if _, err := git.run("rev-parse", "--is-inside-work-tree"); err != nil {
+		if _, err := git.run("init", "-q"); err != nil {
+			return HistoryCommit{}, err
+		}
+	}
+
+	changes, files, err := writeHistory(dir, snapshot.Domains)
+	if err != nil {
+		return HistoryCommit{}, err
+	}
+
+	if _, err := git.run(append([]string{"add", "-A", "--"}, files...)...); err != nil {
+		return HistoryCommit{}, err
+	}
+	if status, err := git.run(append([]string{"status", "--porcelain", "--"}, files...)...); err != nil || status == "" {
+		return HistoryCommit{}, err
+	}
+
+	commit := HistoryCommit{Changed: true, Message: historyMessage(changes)}
+	args := append([]string{"commit", "-q", "-m", commit.Message, "--"}, files...)
+	if email, _ := git.run("config", "user.email"); email == "" {
+		args = append([]string{"-c", "user.name=hoverdns", "-c", "user.email=hoverdns@localhost"}, args...)
+	}
+	if _, err := git.run(args...); err != nil {
+		return HistoryCommit{}, err
+	}
+	return commit, nil
+}
+
+// gitRunner runs git within a directory
+type gitRunner struct {
+	ctx context.Context
+	dir string
+}
+
+// run runs git with the arguments, returning its output trimmed
+func (g gitRunner) run(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(g.ctx, "git", args...)
+	cmd.Dir, cmd.Stdout, cmd.Stderr = g.dir, &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("hover: git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// historyChange is what changed of a single domain
+type historyChange struct {
+	domain  string
+	state   string   // "new", "gone", or blank if changed
+	details bool     // registration details, such as contacts or renewal, changed
+	records []string // records added, removed, or changed, as "+", "-", or "~" lines
+}
+
+// writeHistory writes the files of the domains into the directory, returning what changed from
+// the files already there, and the files written or removed
+func writeHistory(dir string, domains []Domain) ([]historyChange, []string, error) {
+	var (
+		changes []historyChange
+		files   []string
+	)
+	written := make(map[string]bool)
+
+	for _, d := range domains {
+		d.Entries = append([]Entry(nil), d.Entries...)
+		sortEntries(d.Entries)
+		name := strings.ToLower(d.DomainName)
+		written[name+".json"], written[name+".zone"] = true, true
+		files = append(files, name+".json", name+".zone")
+
+		change := historyChange{domain: d.DomainName}
+		if previous, err := readHistoryDomain(filepath.Join(dir, name+".json")); err != nil {
+			return nil, nil, err
+		} else if previous == nil {
+			change.state = "new"
+			for _, e := range d.Entries {
+				change.records = append(change.records, "+ "+historyRecord(e))
+			}
+		} else {
+			change.details = !sameDetails(*previous, d)
+			change.records = diffHistoryRecords(previous.Entries, d.Entries)
+		}
+		if change.state != "" || change.details || len(change.records) > 0 {
+			changes = append(changes, change)
+		}
+
+		data, err := canonicaljson.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".json"), append(data, '\n'), 0600); err != nil {
+			return nil, nil, fmt.Errorf("hover: history: %w", err)
+		}
+		var zone bytes.Buffer
+		if err := WriteZone(&zone, d); err != nil {
+			return nil, nil, err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".zone"), zone.Bytes(), 0600); err != nil {
+			return nil, nil, fmt.Errorf("hover: history: %w", err)
+		}
+	}
+
+	// domains no longer in the account; other files are left alone
+	existing, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("hover: history: %w", err)
+	}
+	for _, f := range existing {
+		if f.IsDir() || written[f.Name()] || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		name := strings.TrimSuffix(f.Name(), ".json")
+		if d, err := readHistoryDomain(filepath.Join(dir, f.Name())); err != nil || d == nil || strings.ToLower(d.DomainName) != name {
+			continue
+		}
+		changes = append(changes, historyChange{domain: name, state: "gone"})
+		for _, gone := range []string{name + ".json", name + ".zone"} {
+			if err := os.Remove(filepath.Join(dir, gone)); err == nil {
+				files = append(files, gone)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return nil, nil, fmt.Errorf("hover: history: %w", err)
+			}
+		}
+	}
+	return changes, files, nil
+}
+
+// readHistoryDomain reads a domain written by a previous snapshot; nil if there's none
+func readHistoryDomain(filename string) (*Domain, error) {
+	data, err := ioutil.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("hover: history: %w", err)
+	}
+
+	var d Domain
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("hover: history: parsing %s: %w", filename, err)
+	}
+	return &d, nil
+}
+
+// sameDetails reports whether the domains are the same but for their entries
+func sameDetails(a, b Domain) bool {
+	a.Entries, b.Entries = nil, nil
+	ja, errA := canonicaljson.Marshal(a)
+	jb, errB := canonicaljson.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
+
+// historyRecord describes a record in a commit message
+func historyRecord(e Entry) string {
+	return fmt.Sprintf("%s %s %s", zoneName(e.Name), e.Type, e.Content)
+}
+
+// diffHistoryRecords lists the records removed, added, or with their TTL changed
+func diffHistoryRecords(before, after []Entry) []string {
+	was := make(map[string]Entry)
+	for _, e := range before {
+		was[historyRecord(e)] = e
+	}
+	is := make(map[string]Entry)
+	for _, e := range after {
+		is[historyRecord(e)] = e
+	}
+
+	var lines []string
+	for _, e := range before {
+		if _, ok := is[historyRecord(e)]; !ok {
+			lines = append(lines, "- "+historyRecord(e))
+		}
+	}
+	for _, e := range after {
+		old, ok := was[historyRecord(e)]
+		switch {
+		case !ok:
+			lines = append(lines, "+ "+historyRecord(e))
+		case old.TTL != e.TTL:
+			lines = append(lines, fmt.Sprintf("~ %s (ttl %d -> %d)", historyRecord(e), old.TTL, e.TTL))
+		}
+	}
+	return lines
+}
+
+// historyMessage summarizes the changes as a commit message: a subject naming the domains
+// changed, then the records changed in each
+func historyMessage(changes []historyChange) string {
+	sort.Slice(changes, func(i, j int) bool { return changes[i].domain < changes[j].domain })
+
+	names := make([]string, 0, len(changes))
+	for _, ch := range changes {
+		names = append(names, ch.domain)
+	}
+	subject := "Snapshot of Hover: " + strings.Join(names, ", ") + " changed"
+	if len(names) > 3 {
+		subject = fmt.Sprintf("Snapshot of Hover: %d domains changed", len(names))
+	}
+	if len(names) == 0 {
+		subject = "Snapshot of Hover"
+	}
+
+	var b strings.Builder
+	b.WriteString(subject + "\n")
+	for _, ch := range changes {
+		b.WriteString("\n" + ch.domain)
+		switch {
+		case ch.state != "":
+			b.WriteString(": " + ch.state)
+		case len(ch.records) > 0:
+			b.WriteString(fmt.Sprintf(": %d record(s) changed", len(ch.records)))
+		}
+		b.WriteString("\n")
+		if ch.details {
+			b.WriteString("  registration details changed\n")
+		}
+		for _, r := range ch.records {
+			b.WriteString("  " + r + "\n")
+		}
+	}
+	return b.String()
+}
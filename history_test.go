@@ -0,0 +1,80 @@
+package hoverdnsapi_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chickenandpork/hoverdnsapi" // to ensure testing without extra access
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCommitHistory checks that each snapshot commits only what changed, and says what it was
+func TestCommitHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	srv, client := newTestClient(t, hoverdnsapi.WithRateLimit(0, 0))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "history")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.json"), []byte(`{"mine": true}`), 0600))
+
+	log := func() []string {
+		out, err := exec.Command("git", "-C", dir, "log", "--format=%s").Output()
+		assert.NoError(t, err)
+		return strings.Split(strings.TrimSpace(string(out)), "\n")
+	}
+
+	commit, err := client.CommitHistory(dir)
+	if assert.NoError(t, err) && assert.True(t, commit.Changed) {
+		assert.Contains(t, commit.Message, "example.com: new\n")
+		assert.Contains(t, commit.Message, "  + www CNAME example.com\n")
+	}
+	json, err := ioutil.ReadFile(filepath.Join(dir, "example.com.json"))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(json), `"domain_name": "example.com"`)
+	}
+	assert.FileExists(t, filepath.Join(dir, "example.com.zone"))
+
+	// nothing changed, nothing committed
+	commit, err = client.CommitHistory(dir)
+	if assert.NoError(t, err) {
+		assert.False(t, commit.Changed)
+	}
+	assert.Len(t, log(), 1)
+
+	assert.NoError(t, client.DoActions(
+		hoverdnsapi.NewRecordAction(hoverdnsapi.Add, "api.example.com", "example.com", "A", "192.0.2.9", 3600),
+		hoverdnsapi.NewRecordAction(hoverdnsapi.Delete, "www.example.com", "example.com", "CNAME", "", 0),
+	))
+	srv.AddDomain(hoverdnsapi.Domain{DomainName: "example.org"})
+	commit, err = client.CommitHistory(dir)
+	if assert.NoError(t, err) && assert.True(t, commit.Changed) {
+		assert.Equal(t, "Snapshot of Hover: example.com, example.org changed", strings.SplitN(commit.Message, "\n", 2)[0])
+		assert.Contains(t, commit.Message, "example.com: 2 record(s) changed\n  - www CNAME example.com\n  + api A 192.0.2.9\n")
+		assert.Contains(t, commit.Message, "example.org: new\n")
+	}
+	assert.Equal(t, "Snapshot of Hover: example.com, example.org changed", log()[0])
+
+	// files of a domain that's gone are removed, and others left
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "example.net.json"), []byte(`{"domain_name": "example.net"}`), 0600))
+	git := exec.Command("git", "-c", "user.name=test", "-c", "user.email=test@localhost", "commit", "-q", "-m", "example.net was here", "--", "example.net.json")
+	git.Dir = dir
+	assert.NoError(t, exec.Command("git", "-C", dir, "add", "example.net.json").Run())
+	assert.NoError(t, git.Run())
+	commit, err = client.CommitHistory(dir)
+	if assert.NoError(t, err) {
+		assert.Contains(t, commit.Message, "example.net: gone\n")
+	}
+	_, err = os.Stat(filepath.Join(dir, "example.net.json"))
+	assert.True(t, os.IsNotExist(err))
+	assert.FileExists(t, filepath.Join(dir, "notes.json"))
+}
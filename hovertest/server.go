@@ -0,0 +1,592 @@
+// Package hovertest offers an in-process fake of Hover's DNS API so that code using hoverdnsapi
+// can be tested end-to-end without hitting hover.com.  It's only as accurate as what I've seen
+// Hover do: please PR corrections if you catch it disagreeing with the real thing.
+//
+// For example:
+//
+//	srv := hovertest.NewServer()
+//	defer srv.Close()
+//	srv.AddUser("scott", "tiger")
+//	srv.AddDomain(hoverdnsapi.Domain{DomainName: "example.com"})
+//
+//	client, err := hoverdnsapi.New(
+//		hoverdnsapi.WithCredentials("scott", "tiger"),
+//		hoverdnsapi.WithBaseURL(srv.BaseURL()),
+//	)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+package hovertest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chickenandpork/hoverdnsapi"
+)
+
+const (
+	authCookie    = "hoverauth"
+	pendingCookie = "hover_session" // tracks a login waiting for its 2FA code
+
+	// DefaultTTL is the TTL given to entries created without one, as Hover seems to do
+	DefaultTTL = 900
+)
+
+// Fault describes a failure to inject into the requests matching Method and Path.  A Fault can
+// delay a response, replace it with a given status and body, or both.
+type Fault struct {
+	Method string        // HTTP method to match, ie "PUT"; blank matches any
+	Path   string        // path prefix to match, ie "/api/dns"; blank matches any
+	Status int           // status to respond with; zero lets the request through after Delay
+	Body   string        // body to respond with, if Status is given
+	Header http.Header   // headers to add to the response, ie "Retry-After"
+	Delay  time.Duration // time to wait before responding (honouring the client giving up)
+	Times  int           // number of requests to affect; zero affects every request
+
+	// Lost lets the request take effect before responding with Status, as if the real response
+	// were lost on the way back: useful to check that a non-idempotent request isn't repeated
+	Lost bool
+}
+
+func (f Fault) matches(r *http.Request) bool {
+	return (f.Method == "" || strings.EqualFold(f.Method, r.Method)) && strings.HasPrefix(r.URL.Path, f.Path)
+}
+
+// Server is a fake Hover API listening on a local port.  Its state (users, domains, entries,
+// sessions) is held in memory and can be inspected by tests.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	users    map[string]string // username -> password
+	sessions map[string]string // auth cookie -> username
+	pending  map[string]string // pending cookie -> username, awaiting a 2FA code
+	factors  map[string]secondFactor
+	domains  []hoverdnsapi.Domain
+	faults   []*Fault
+	requests []string
+	nextID   int
+	authFail bool
+	redirect bool // send the unauthenticated to the login page, rather than a 401
+}
+
+// NewServer starts a fake Hover API; Close() it when done
+func NewServer() *Server {
+	s := &Server{
+		users:    make(map[string]string),
+		sessions: make(map[string]string),
+		pending:  make(map[string]string),
+		factors:  make(map[string]secondFactor),
+		domains:  make([]hoverdnsapi.Domain, 0),
+		nextID:   1000,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// BaseURL is the root of the fake API, to be given to hoverdnsapi.WithBaseURL()
+func (s *Server) BaseURL() string {
+	return s.URL + "/api"
+}
+
+// AddUser permits the given username/password to log in
+func (s *Server) AddUser(username, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[username] = password
+}
+
+// secondFactor is the 2FA configured for a user
+type secondFactor struct {
+	method string // hoverdnsapi.SecondFactorApp or SecondFactorEmail
+	secret string // base32 TOTP secret for an app, or the fixed code expected by email
+}
+
+// RequireSecondFactor enables 2FA for a user.  For hoverdnsapi.SecondFactorApp, the secret is the
+// base32 TOTP secret, and codes within a step of the current time are accepted; for
+// hoverdnsapi.SecondFactorEmail, the secret is simply the code expected.
+//
+// The "need_2fa" login status and the login/2fa endpoint are this fake's guess at Hover's
+// sign-in, not confirmed against hover.com, so passing against them is no proof of working there.
+func (s *Server) RequireSecondFactor(username, method, secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.factors[username] = secondFactor{method: method, secret: secret}
+}
+
+// AddDomain adds a domain to the account, returning it as stored: an ID is assigned to the domain
+// and to any entries if not given.
+func (s *Server) AddDomain(d hoverdnsapi.Domain) hoverdnsapi.Domain {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d.ID == "" {
+		d.ID = s.newID("dom")
+	}
+	d.Active = true
+	entries := make([]hoverdnsapi.Entry, 0, len(d.Entries))
+	for _, e := range d.Entries {
+		if e.ID == "" {
+			e.ID = s.newID("dns")
+		}
+		if e.TTL == 0 {
+			e.TTL = DefaultTTL
+		}
+		entries = append(entries, e)
+	}
+	d.Entries = entries
+	s.domains = append(s.domains, d)
+
+	return copyDomain(d)
+}
+
+// Domain returns a copy of the named domain, including its entries
+func (s *Server) Domain(name string) (hoverdnsapi.Domain, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if d := s.findDomain(name); d != nil {
+		return copyDomain(*d), true
+	}
+	return hoverdnsapi.Domain{}, false
+}
+
+// Entries returns a copy of the entries of the named domain
+func (s *Server) Entries(name string) []hoverdnsapi.Entry {
+	d, _ := s.Domain(name)
+	return d.Entries
+}
+
+// FailAuth causes every login to be refused while set, as if the password were wrong
+func (s *Server) FailAuth(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.authFail = fail
+}
+
+// RedirectToLogin causes requests without a session to be redirected to the login page while
+// set, as the website does, rather than refused with a 401
+func (s *Server) RedirectToLogin(redirect bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.redirect = redirect
+}
+
+// Inject adds a Fault; faults are checked in the order injected, and the first to match a
+// request applies.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes all injected faults
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// Requests returns the requests received so far, as "METHOD /path"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// ExpireSessions forgets every login, as if the sessions had timed out
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions = make(map[string]string)
+}
+
+// newID generates a Hover-like opaque ID such as "dns1001"; call with the lock held
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s%d", prefix, s.nextID)
+}
+
+// findDomain finds a domain by ID or name, as Hover accepts either; call with the lock held
+func (s *Server) findDomain(idOrName string) *hoverdnsapi.Domain {
+	for n := range s.domains {
+		if s.domains[n].ID == idOrName || s.domains[n].DomainName == idOrName {
+			return &s.domains[n]
+		}
+	}
+	return nil
+}
+
+// findEntry finds an entry by ID across all domains; call with the lock held
+func (s *Server) findEntry(id string) (*hoverdnsapi.Domain, int) {
+	for n := range s.domains {
+		for m := range s.domains[n].Entries {
+			if s.domains[n].Entries[m].ID == id {
+				return &s.domains[n], m
+			}
+		}
+	}
+	return nil, -1
+}
+
+// fault finds the first fault matching the request, using up one of its Times
+func (s *Server) fault(r *http.Request) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for n, f := range s.faults {
+		if f.matches(r) {
+			if f.Times > 0 {
+				f.Times--
+				if f.Times == 0 {
+					s.faults = append(s.faults[:n], s.faults[n+1:]...)
+				}
+			}
+			return f
+		}
+	}
+	return nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	s.mu.Unlock()
+
+	if f := s.fault(r); f != nil {
+		if f.Delay > 0 {
+			select {
+			case <-time.After(f.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if f.Status != 0 {
+			if f.Lost {
+				s.route(httptest.NewRecorder(), r)
+			}
+			for k, v := range f.Header {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(f.Status)
+			fmt.Fprint(w, f.Body)
+			return
+		}
+	}
+
+	s.route(w, r)
+}
+
+// route dispatches the request to the handler of the resource
+func (s *Server) route(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api"), "/"), "/")
+	switch {
+	case len(path) == 1 && path[0] == "login":
+		s.login(w, r)
+		return
+	case len(path) == 2 && path[0] == "login" && path[1] == "2fa":
+		s.loginSecondFactor(w, r)
+		return
+	case len(path) == 1 && path[0] == "signin" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintln(w, "<html><body><form method=post>Sign in to Hover</form></body></html>")
+		return
+	case !s.authenticated(r):
+		s.mu.Lock()
+		redirect := s.redirect
+		s.mu.Unlock()
+		if redirect {
+			http.Redirect(w, r, "/signin", http.StatusFound)
+			return
+		}
+		writeError(w, http.StatusUnauthorized, "login_required", "You must be logged in to do that")
+		return
+	}
+
+	switch {
+	case len(path) == 1 && path[0] == "domains" && r.Method == http.MethodGet:
+		s.listDomains(w)
+	case len(path) == 3 && path[0] == "domains" && path[2] == "dns" && r.Method == http.MethodGet:
+		s.listEntries(w, path[1])
+	case len(path) == 3 && path[0] == "domains" && path[2] == "dns" && r.Method == http.MethodPost:
+		s.createEntry(w, r, path[1])
+	case len(path) == 4 && path[0] == "domains" && path[2] == "dns" && r.Method == http.MethodDelete:
+		s.deleteEntry(w, path[3])
+	case len(path) == 2 && path[0] == "dns" && r.Method == http.MethodPut:
+		s.updateEntry(w, r, path[1])
+	case len(path) == 2 && path[0] == "dns" && r.Method == http.MethodDelete:
+		s.deleteEntry(w, path[1])
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "No such resource")
+	}
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Login is a POST")
+		return
+	}
+
+	username, password := r.PostFormValue("username"), r.PostFormValue("password")
+
+	s.mu.Lock()
+	expected, ok := s.users[username]
+	ok = ok && expected == password && !s.authFail
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "login_failed", "Invalid username or password")
+		return
+	}
+
+	s.mu.Lock()
+	factor, needed := s.factors[username]
+	s.mu.Unlock()
+
+	if needed {
+		pending := newToken()
+		s.mu.Lock()
+		s.pending[pending] = username
+		s.mu.Unlock()
+
+		http.SetCookie(w, &http.Cookie{Name: pendingCookie, Value: pending, Path: "/", HttpOnly: true})
+		writeJSON(w, http.StatusOK, map[string]interface{}{"succeeded": true, "status": "need_2fa", "type": factor.method})
+		return
+	}
+
+	s.startSession(w, username)
+}
+
+// loginSecondFactor completes a login pending a 2FA code
+func (s *Server) loginSecondFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "Login is a POST")
+		return
+	}
+
+	c, err := r.Cookie(pendingCookie)
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "login_required", "No login awaiting a code")
+		return
+	}
+
+	s.mu.Lock()
+	username, ok := s.pending[c.Value]
+	factor := s.factors[username]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "login_required", "No login awaiting a code")
+		return
+	}
+	if !factor.accepts(r.PostFormValue("code"), time.Now()) {
+		writeError(w, http.StatusUnauthorized, "invalid_code", "Invalid verification code")
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.pending, c.Value)
+	s.mu.Unlock()
+
+	s.startSession(w, username)
+}
+
+// accepts checks a 2FA code; TOTP codes of the adjacent steps are allowed for clock skew
+func (f secondFactor) accepts(code string, now time.Time) bool {
+	if f.method != hoverdnsapi.SecondFactorApp {
+		return code != "" && code == f.secret
+	}
+	for _, skew := range []time.Duration{0, -hoverdnsapi.TOTPStep, hoverdnsapi.TOTPStep} {
+		if expected, err := hoverdnsapi.TOTPCode(f.secret, now.Add(skew)); err == nil && code == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// startSession logs in the user, setting the auth cookie
+func (s *Server) startSession(w http.ResponseWriter, username string) {
+	session := newToken()
+
+	s.mu.Lock()
+	s.sessions[session] = username
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: authCookie, Value: session, Path: "/", HttpOnly: true})
+	writeJSON(w, http.StatusOK, hoverdnsapi.APIResponse{Succeeded: true})
+}
+
+// newToken generates a random session token
+func newToken() string {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+func (s *Server) authenticated(r *http.Request) bool {
+	c, err := r.Cookie(authCookie)
+	if err != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.sessions[c.Value]
+	return ok
+}
+
+func (s *Server) listDomains(w http.ResponseWriter) {
+	s.mu.Lock()
+	list := hoverdnsapi.DomainList{Succeeded: true, Domains: make([]hoverdnsapi.Domain, 0, len(s.domains))}
+	for _, d := range s.domains {
+		d.Entries = nil // only given when a single domain is expanded
+		list.Domains = append(list.Domains, d)
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) listEntries(w http.ResponseWriter, idOrName string) {
+	s.mu.Lock()
+	d := s.findDomain(idOrName)
+	var list hoverdnsapi.DomainList
+	if d != nil {
+		list = hoverdnsapi.DomainList{Succeeded: true, Domains: []hoverdnsapi.Domain{copyDomain(*d)}}
+	}
+	s.mu.Unlock()
+
+	if d == nil {
+		writeError(w, http.StatusNotFound, "domain_not_found", "No such domain")
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) createEntry(w http.ResponseWriter, r *http.Request, idOrName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.findDomain(idOrName)
+	if d == nil {
+		writeError(w, http.StatusNotFound, "domain_not_found", "No such domain")
+		return
+	}
+
+	e := hoverdnsapi.Entry{
+		Name:    relativeName(r.PostFormValue("name"), d.DomainName),
+		Type:    strings.ToUpper(r.PostFormValue("type")),
+		Content: r.PostFormValue("content"),
+		TTL:     DefaultTTL,
+	}
+	if e.Name == "" || e.Content == "" || !hoverdnsapi.ValidRecordType(e.Type) {
+		writeError(w, http.StatusUnprocessableEntity, "unactionable", "A name, a valid type, and content are needed")
+		return
+	}
+	if ttl := r.PostFormValue("ttl"); ttl != "" {
+		n, err := strconv.Atoi(ttl)
+		if err != nil || n <= 0 || !hoverdnsapi.ValidTTL(uint(n)) {
+			writeError(w, http.StatusUnprocessableEntity, "unactionable", "Invalid TTL")
+			return
+		}
+		e.TTL = n
+	}
+	e.ID = s.newID("dns")
+	d.Entries = append(d.Entries, e)
+
+	writeJSON(w, http.StatusOK, entryResponse{Succeeded: true, Entry: e})
+}
+
+func (s *Server) updateEntry(w http.ResponseWriter, r *http.Request, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, n := s.findEntry(id)
+	if d == nil {
+		writeError(w, http.StatusNotFound, "record_not_found", "No such record")
+		return
+	}
+
+	e := d.Entries[n]
+	if content := r.PostFormValue("content"); content != "" {
+		e.Content = content
+	}
+	if ttl := r.PostFormValue("ttl"); ttl != "" {
+		v, err := strconv.Atoi(ttl)
+		if err != nil || v <= 0 || !hoverdnsapi.ValidTTL(uint(v)) {
+			writeError(w, http.StatusUnprocessableEntity, "unactionable", "Invalid TTL")
+			return
+		}
+		e.TTL = v
+	}
+	d.Entries[n] = e
+
+	writeJSON(w, http.StatusOK, entryResponse{Succeeded: true, Entry: e})
+}
+
+func (s *Server) deleteEntry(w http.ResponseWriter, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, n := s.findEntry(id)
+	if d == nil {
+		writeError(w, http.StatusNotFound, "record_not_found", "No such record")
+		return
+	}
+	d.Entries = append(d.Entries[:n], d.Entries[n+1:]...)
+
+	writeJSON(w, http.StatusOK, hoverdnsapi.APIResponse{Succeeded: true})
+}
+
+// entryResponse is the response to creating or updating an entry: the envelope plus the entry
+type entryResponse struct {
+	Succeeded bool `json:"succeeded"`
+	hoverdnsapi.Entry
+}
+
+// relativeName maps an FQDN (or already-relative name) to the name Hover stores in an Entry: the
+// apex is "@"
+func relativeName(name, domain string) string {
+	name = strings.TrimSuffix(name, ".")
+	switch {
+	case name == domain:
+		return "@"
+	case strings.HasSuffix(name, "."+domain):
+		return strings.TrimSuffix(name, "."+domain)
+	}
+	return name
+}
+
+func copyDomain(d hoverdnsapi.Domain) hoverdnsapi.Domain {
+	d.Entries = append([]hoverdnsapi.Entry(nil), d.Entries...)
+	return d
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, hoverdnsapi.APIResponse{Succeeded: false, ErrorCode: code, Error: msg})
+}
@@ -0,0 +1,76 @@
+package hoverdnsapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// newRequest creates a request bound to the context; if values are given, they're form-encoded
+// as the body of the request.
+func (c *Client) newRequest(ctx context.Context, method, url string, values url.Values) (*http.Request, error) {
+	var body io.Reader
+	if values != nil {
+		body = strings.NewReader(values.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if values != nil {
+		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+	return req, nil
+}
+
+// HTTPDelete actually does an HTTP call with the DELETE method.  BOG-standard Go only offers GET
+// and POST.
+func (c *Client) HTTPDelete(url string) (err error) {
+	return c.HTTPDeleteContext(context.Background(), url)
+}
+
+// HTTPDeleteContext is HTTPDelete bound to a context for cancellation and deadlines
+func (c *Client) HTTPDeleteContext(ctx context.Context, url string) (err error) {
+	resp, err := c.do(ctx, apiCall{method: http.MethodDelete, url: url})
+	if err != nil {
+		return fmt.Errorf("HTTPDelete: executing delete request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if _, err := readResponse(resp); err != nil {
+		return fmt.Errorf("HTTPDelete: %w", err)
+	}
+	return nil
+}
+
+// HTTPUpdate actually does an HTTP call with the PUT method, form-encoding the given values.
+// BOG-standard Go only offers GET and POST.
+//
+// # update an existing DNS record: client.call("put", "dns/dns1234567", {"content": "127.0.0.1"})
+//
+// Hover has been seen to refuse these with a 422 (Unactionable); the error returned carries the
+// status so that DoActions can fall back to a delete/add.
+func (c *Client) HTTPUpdate(url string, values url.Values) (err error) {
+	return c.HTTPUpdateContext(context.Background(), url, values)
+}
+
+// HTTPUpdateContext is HTTPUpdate bound to a context for cancellation and deadlines
+func (c *Client) HTTPUpdateContext(ctx context.Context, url string, values url.Values) (err error) {
+	resp, err := c.do(ctx, apiCall{method: http.MethodPut, url: url, values: values})
+	if err != nil {
+		return fmt.Errorf("HTTPUpdate: executing put request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if _, err := readResponse(resp); err != nil {
+		return fmt.Errorf("HTTPUpdate: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package hoverdnsapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	golog "log"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"time"
+)
+
+const (
+	// DefaultTimeout is the timeout of each HTTP request made by a Client from New, unless
+	// overridden by WithTimeout or WithHTTPClient
+	DefaultTimeout = 30 * time.Second
+
+	// DefaultUserAgent identifies this library to Hover, unless overridden by WithUserAgent
+	DefaultUserAgent = "hoverdnsapi (+https://github.com/chickenandpork/hoverdnsapi)"
+)
+
+// Option configures a Client created by New.  Options are applied in order, so a later option
+// overrides an earlier one (ie WithHTTPClient replaces the timeout set by WithTimeout).
+type Option func(*Client) error
+
+// New creates a Hover client configured by the given options.  Credentials are needed, either
+// from WithCredentials or WithCredentialsFile; any option that can't be applied is returned as
+// an error rather than silently ignored.
+//
+// For example:
+//
+//	client, err := hoverdnsapi.New(
+//		hoverdnsapi.WithCredentialsFile("/etc/hover/passfile.json"),
+//		hoverdnsapi.WithTimeout(10*time.Second),
+//	)
+func New(opts ...Option) (*Client, error) {
+	c := defaultClient()
+
+	for _, opt := range opts {
+		if opt == nil {
+			return nil, errors.New("hover: nil option")
+		}
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if !c.offline && (c.Username == "" || c.Password == "") {
+		return nil, errors.New("hover: no credentials: use WithCredentials or WithCredentialsFile")
+	}
+
+	return c, nil
+}
+
+// defaultClient returns a Client with a fresh cookie jar, the default base URL and timeout, and
+// logging to stderr
+func defaultClient() *Client {
+	j, _ := cookiejar.New(nil)
+	base := *parsedBaseURL
+
+	return &Client{
+		HTTPClient: &http.Client{
+			Jar:     j,
+			Timeout: DefaultTimeout,
+		},
+		BaseURL:   &base,
+		log:       golog.New(os.Stderr, "", golog.LstdFlags),
+		userAgent: DefaultUserAgent,
+		retry:     DefaultRetryPolicy,
+		limiter:   newRateLimiter(DefaultRateLimit),
+		cacheTTL:  DefaultCacheTTL,
+	}
+}
+
+// WithCredentials sets the username and password used to log in, exactly as typed in the login
+// form
+func WithCredentials(username, password string) Option {
+	return func(c *Client) error {
+		if username == "" || password == "" {
+			return errors.New("hover: WithCredentials: username and password are needed")
+		}
+		c.Username = username
+		c.Password = password
+		return nil
+	}
+}
+
+// WithCredentialsFile reads the username and password from a file, as per ReadConfigFile
+func WithCredentialsFile(filename string) Option {
+	return func(c *Client) error {
+		observed, err := ReadConfigFile(filename)
+		if err != nil {
+			return fmt.Errorf("hover: WithCredentialsFile %s: %w", filename, err)
+		}
+		if observed.Username == "" || observed.PlaintextPassword == "" {
+			return fmt.Errorf("hover: WithCredentialsFile %s: username and plaintextpassword are needed", filename)
+		}
+		c.Username = observed.Username
+		c.Password = observed.PlaintextPassword
+		if observed.TOTPSecret != "" {
+			return WithTOTPSecret(observed.TOTPSecret)(c)
+		}
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout of each HTTP request; zero means no timeout
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		if timeout < 0 {
+			return fmt.Errorf("hover: WithTimeout: negative timeout %s", timeout)
+		}
+		c.HTTPClient.Timeout = timeout
+		return nil
+	}
+}
+
+// WithLogger sets the logger; use a NopLogger to discard logging
+func WithLogger(logger YALI) Option {
+	return func(c *Client) error {
+		if logger == nil {
+			return errors.New("hover: WithLogger: nil logger")
+		}
+		c.log = logger
+		return nil
+	}
+}
+
+// WithHTTPClient uses a copy of the given http.Client for requests.  If it has no cookie jar, one
+// is added, since the login session is tracked as a cookie.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) error {
+		if client == nil {
+			return errors.New("hover: WithHTTPClient: nil client")
+		}
+		hc := *client
+		if hc.Jar == nil {
+			hc.Jar = c.HTTPClient.Jar
+		}
+		c.HTTPClient = &hc
+		return nil
+	}
+}
+
+// WithTransport sets the RoundTripper used for requests, such as a recording or instrumented
+// transport
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) error {
+		if transport == nil {
+			return errors.New("hover: WithTransport: nil transport")
+		}
+		c.HTTPClient.Transport = transport
+		return nil
+	}
+}
+
+// WithBaseURL sets the root of the API, as per Client.SetBaseURL
+func WithBaseURL(rawURL string) Option {
+	return func(c *Client) error {
+		return c.SetBaseURL(rawURL)
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent on each request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) error {
+		if userAgent == "" {
+			return errors.New("hover: WithUserAgent: empty user-agent")
+		}
+		c.userAgent = userAgent
+		return nil
+	}
+}
+
+// SecondFactorFunc supplies a 2FA code when Hover asks for one at login, such as by prompting the
+// user.  The method is how Hover delivered the code: SecondFactorApp or SecondFactorEmail.
+//
+// 2FA support is experimental: the login flow is only confirmed against hovertest, not Hover.
+type SecondFactorFunc func(ctx context.Context, method string) (string, error)
+
+// WithTOTPSecret sets the base32 secret of the authenticator app registered for 2FA, so that codes
+// are generated without interaction.  The secret is as sensitive as the password.  Experimental,
+// as is all 2FA support; see SecondFactorFunc.
+func WithTOTPSecret(secret string) Option {
+	return func(c *Client) error {
+		if _, err := decodeTOTPSecret(secret); err != nil {
+			return fmt.Errorf("hover: WithTOTPSecret: %w", err)
+		}
+		c.totpSecret = secret
+		return nil
+	}
+}
+
+// WithSecondFactorPrompt sets the function asked for a 2FA code that can't be generated, ie one
+// sent by email, or from an app whose secret isn't given to WithTOTPSecret.  Experimental, as is
+// all 2FA support; see SecondFactorFunc.
+func WithSecondFactorPrompt(prompt SecondFactorFunc) Option {
+	return func(c *Client) error {
+		if prompt == nil {
+			return errors.New("hover: WithSecondFactorPrompt: nil prompt")
+		}
+		c.secondFactor = prompt
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package hoverdnsapi_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	"testing"
+
+	"github.com/chickenandpork/hoverdnsapi" // to ensure testing without extra access
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewOptions checks that New refuses options it can't apply, rather than falling back
+func TestNewOptions(t *testing.T) {
+	badfile, err := ioutil.TempFile("", "testfile")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Remove(badfile.Name())
+	badfile.Write([]byte(`{"username": "scott", "plaintextpassword": `))
+
+	var tests = []struct {
+		desc    string
+		opts    []hoverdnsapi.Option
+		success bool
+	}{
+		{"credentials", []hoverdnsapi.Option{hoverdnsapi.WithCredentials("scott", "tiger")}, true},
+		{"no credentials", []hoverdnsapi.Option{hoverdnsapi.WithTimeout(time.Second)}, false},
+		{"blank password", []hoverdnsapi.Option{hoverdnsapi.WithCredentials("scott", "")}, false},
+		{"missing passfile", []hoverdnsapi.Option{hoverdnsapi.WithCredentialsFile("/nonexistent/passfile.json")}, false},
+		{"unparseable passfile", []hoverdnsapi.Option{hoverdnsapi.WithCredentialsFile(badfile.Name())}, false},
+		{"bad base URL", []hoverdnsapi.Option{hoverdnsapi.WithCredentials("scott", "tiger"), hoverdnsapi.WithBaseURL("::nope")}, false},
+		{"negative timeout", []hoverdnsapi.Option{hoverdnsapi.WithCredentials("scott", "tiger"), hoverdnsapi.WithTimeout(-time.Second)}, false},
+		{"nil logger", []hoverdnsapi.Option{hoverdnsapi.WithCredentials("scott", "tiger"), hoverdnsapi.WithLogger(nil)}, false},
+		{"nil client", []hoverdnsapi.Option{hoverdnsapi.WithCredentials("scott", "tiger"), hoverdnsapi.WithHTTPClient(nil)}, false},
+		{"http client", []hoverdnsapi.Option{hoverdnsapi.WithCredentials("scott", "tiger"), hoverdnsapi.WithHTTPClient(&http.Client{})}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			client, err := hoverdnsapi.New(test.opts...)
+			if test.success {
+				assert.NoError(t, err)
+				assert.NotNil(t, client)
+			} else {
+				assert.Error(t, err)
+				assert.Nil(t, client)
+			}
+		})
+	}
+}
+
+// TestWithHTTPClientJar confirms that a given http.Client without a cookie jar gets one, since
+// the login session is a cookie
+func TestWithHTTPClientJar(t *testing.T) {
+	given := &http.Client{Timeout: time.Second}
+	client, err := hoverdnsapi.New(hoverdnsapi.WithCredentials("scott", "tiger"), hoverdnsapi.WithHTTPClient(given))
+	if assert.NoError(t, err) {
+		assert.NotNil(t, client.HTTPClient.Jar)
+		assert.Nil(t, given.Jar, "the caller's client should be left untouched")
+	}
+}
+
+// TestWithUserAgent confirms that the User-Agent reaches the server
+func TestWithUserAgent(t *testing.T) {
+	seen := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case seen <- r.UserAgent():
+		default:
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	client, err := hoverdnsapi.New(
+		hoverdnsapi.WithCredentials("scott", "tiger"),
+		hoverdnsapi.WithBaseURL(srv.URL+"/api"),
+		hoverdnsapi.WithUserAgent("tester/1.0"),
+		hoverdnsapi.WithLogger(&hoverdnsapi.NopLogger{}),
+	)
+	if assert.NoError(t, err) {
+		_, err = client.GetAuth()
+		assert.Error(t, err)
+		assert.Equal(t, "tester/1.0", <-seen)
+	}
+}
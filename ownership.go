@@ -0,0 +1,281 @@
+package hoverdnsapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ownerMarkerLabel prefixes the name of the TXT record that marks a name as owned, such as
+// "_hoverdns-owner.www" for "www", or "_hoverdns-owner" for the domain itself
+const ownerMarkerLabel = "_hoverdns-owner"
+
+// Ownership is a policy of which records a client may change, for when a domain is shared with
+// other teams, other tools, or Hover itself (see WithOwnership):
+//
+//   - records that Hover manages (Entry.Default or Entry.CanRevert, such as the MX records of
+//     Hover's email) are never changed or deleted; without a policy, they're still never deleted
+//   - records matching an IgnoreRule are never changed or deleted
+//   - if Owner is set, only names marked as owned are changed: the marker is a TXT record at
+//     "_hoverdns-owner.<name>" holding "heritage=hoverdns,owner=<Owner>", much as external-dns
+//     does.  Plan writes the markers of the names it adds, and removes them with the names.
+//
+// A change refused by the policy is ErrNotOwned.
+type Ownership struct {
+	Owner  string       // identifies the records of this client in their markers; blank for no markers
+	Adopt  bool         // Plan claims names of the desired state that no one has marked, rather than refusing them
+	Ignore []IgnoreRule // records never to change
+}
+
+// IgnoreRule picks records to leave alone: those matching every field given
+type IgnoreRule struct {
+	Name    string `json:"name,omitempty" yaml:"name,omitempty"`       // relative name as Hover shows it (ie "www", "@")
+	Type    string `json:"type,omitempty" yaml:"type,omitempty"`       // record type, ie TypeMX
+	Pattern string `json:"pattern,omitempty" yaml:"pattern,omitempty"` // regular expression matched against the relative name
+
+	re *regexp.Regexp
+}
+
+// matches reports whether the rule picks the entry
+func (r IgnoreRule) matches(e Entry) bool {
+	if r.Name != "" && !strings.EqualFold(r.Name, e.Name) {
+		return false
+	}
+	if r.Type != "" && !strings.EqualFold(r.Type, e.Type) {
+		return false
+	}
+	return r.re == nil || r.re.MatchString(e.Name)
+}
+
+// WithOwnership limits the records that the client changes to those the policy allows; see
+// Ownership.  It applies to Delete, Update, and Plan, and to the record methods such as
+// UpdateRecord and DeleteRecordByID; adds of new records are unaffected.
+func WithOwnership(o Ownership) Option {
+	return func(c *Client) error {
+		compiled, err := o.compile()
+		if err != nil {
+			return err
+		}
+		c.ownership = &compiled
+		return nil
+	}
+}
+
+// compile checks the ignore rules and compiles their patterns, returning a copy of the policy
+func (o Ownership) compile() (Ownership, error) {
+	rules := make([]IgnoreRule, 0, len(o.Ignore))
+	for _, r := range o.Ignore {
+		if r.Name == "" && r.Type == "" && r.Pattern == "" {
+			return o, errors.New("hover: ignore rule matches every record; give a name, type, or pattern")
+		}
+		if r.Pattern != "" && r.re == nil {
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return o, fmt.Errorf("hover: ignore rule pattern %q: %w", r.Pattern, err)
+			}
+			r.re = re
+		}
+		rules = append(rules, r)
+	}
+	o.Ignore = rules
+	return o, nil
+}
+
+// marker is the content of the TXT record marking names owned
+func (o Ownership) marker() string {
+	return "heritage=hoverdns,owner=" + o.Owner
+}
+
+// markerName is the name of the TXT record marking the name; a wildcard is spelled out, since
+// "*" can only lead a name
+func markerName(name string) string {
+	switch {
+	case name == "@" || name == "":
+		return ownerMarkerLabel
+	case name == "*" || strings.HasPrefix(name, "*."):
+		name = "_wildcard" + name[1:]
+	}
+	return ownerMarkerLabel + "." + name
+}
+
+// markedName returns the name that an entry marks, if it's a marker at all
+func (o Ownership) markedName(e Entry) (string, bool) {
+	if o.Owner == "" || !strings.EqualFold(e.Type, TypeTXT) {
+		return "", false
+	}
+	name := strings.ToLower(e.Name)
+	switch {
+	case name == ownerMarkerLabel:
+		return "@", true
+	case strings.HasPrefix(name, ownerMarkerLabel+"."):
+		name = strings.TrimPrefix(name, ownerMarkerLabel+".")
+		if name == "_wildcard" || strings.HasPrefix(name, "_wildcard.") {
+			name = "*" + strings.TrimPrefix(name, "_wildcard")
+		}
+		return name, true
+	}
+	return "", false
+}
+
+// markerOwner returns the owner named by the content of a marker
+func markerOwner(content string) string {
+	for _, field := range strings.Split(content, ",") {
+		if strings.HasPrefix(field, "owner=") {
+			return strings.TrimPrefix(field, "owner=")
+		}
+	}
+	return ""
+}
+
+// hoverManaged reports whether Hover manages the entry, such as its parking page and email, which
+// is never deleted with or without an Ownership policy
+func hoverManaged(e Entry) bool {
+	return e.Default || e.CanRevert
+}
+
+// errHoverManaged is the refusal to delete an entry that Hover manages
+func errHoverManaged(domain string, e Entry) error {
+	return fmt.Errorf("hover: %s %s in %s is managed by Hover: %w", e.Type, e.Name, domain, ErrNotOwned)
+}
+
+// refuses returns why the policy won't let the entry be changed, ignoring markers; "" if it will
+func (o Ownership) refuses(e Entry) string {
+	switch {
+	case hoverManaged(e):
+		return "is managed by Hover"
+	}
+	for _, r := range o.Ignore {
+		if r.matches(e) {
+			return "is ignored"
+		}
+	}
+	return ""
+}
+
+// Diff is the package's Diff of the desired records, limited by the policy: records not owned
+// are left alone, and a desired record is satisfied by one that isn't owned if they're the same,
+// but otherwise conflicts with it (ErrNotOwned).  Desired records that the policy ignores are
+// dropped.  If Owner is set, markers are added ahead of the other actions, and removed behind
+// them.
+func (o Ownership) Diff(d Domain, desired []Entry) ([]Action, error) {
+	o, err := o.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	want := make([]Entry, 0, len(desired))
+	wanted := make(map[string]bool) // names
+	for _, e := range desired {
+		e, err := desiredEntry(d, e)
+		if err != nil {
+			return nil, err
+		}
+		if o.refuses(e) == "" {
+			want = append(want, e)
+			wanted[e.Name] = true
+		}
+	}
+
+	owners := make(map[string]string) // by name marked
+	ours := make(map[string]Entry)    // our markers, by name marked
+	for _, e := range d.Entries {
+		if name, ok := o.markedName(e); ok {
+			owners[name] = markerOwner(e.Content)
+			if owners[name] == o.Owner {
+				ours[name] = e
+			}
+		}
+	}
+	claimed := func(name string) bool {
+		owner, marked := owners[strings.ToLower(name)]
+		return o.Owner == "" || (marked && owner == o.Owner) || (!marked && o.Adopt && wanted[name])
+	}
+
+	var mine []Entry
+	held := make(map[string]bool) // by planKey and content, of records not ours
+	heldKeys := make(map[string]bool)
+	populated := make(map[string]bool) // names
+	for _, e := range d.Entries {
+		if _, ok := o.markedName(e); ok {
+			continue
+		}
+		populated[strings.ToLower(e.Name)] = true
+		if o.refuses(e) == "" && claimed(e.Name) {
+			mine = append(mine, e)
+			continue
+		}
+		held[planKey(e)+" "+e.Content] = true
+		heldKeys[planKey(e)] = true
+	}
+
+	unheld := make([]Entry, 0, len(want))
+	remaining := make(map[string]bool) // names with records left to keep or make
+	for _, e := range want {
+		switch {
+		case held[planKey(e)+" "+e.Content]:
+			continue // already there, if not ours
+		case heldKeys[planKey(e)] || (populated[e.Name] && !claimed(e.Name)) || owners[e.Name] != o.Owner && owners[e.Name] != "":
+			return nil, fmt.Errorf("hover: desired record %s %s conflicts with records in %s not owned: %w", e.Name, e.Type, d.DomainName, ErrNotOwned)
+		}
+		unheld = append(unheld, e)
+		remaining[e.Name] = true
+	}
+
+	owned := d
+	owned.Entries = mine
+	actions, err := Diff(owned, unheld)
+	if err != nil || o.Owner == "" {
+		return actions, err
+	}
+
+	var marks, unmarks []Action
+	for _, e := range unheld {
+		if _, ok := ours[e.Name]; !ok {
+			ours[e.Name] = Entry{} // only once
+			marks = append(marks, planAction(Add, d, Entry{Name: markerName(e.Name), Type: TypeTXT, Content: o.marker()}))
+		}
+	}
+	for name, m := range ours {
+		if m.ID != "" && !remaining[name] {
+			unmarks = append(unmarks, planAction(Delete, d, m).WithEntryID(m.ID))
+		}
+	}
+	sort.Slice(unmarks, func(i, j int) bool { return unmarks[i].fqdn < unmarks[j].fqdn })
+	return append(append(marks, actions...), unmarks...), nil
+}
+
+// mayChange checks that the policy of the client, if any, allows the entry of the domain to be
+// changed or deleted; the markers of the domain are read if need be.  Entries that Hover manages
+// are refused for deletion by deleteEntry whether there's a policy or not.
+func (c *Client) mayChange(ctx context.Context, domain string, e Entry) error {
+	o := c.ownership
+	if o == nil {
+		return nil
+	}
+	if _, ok := o.markedName(e); ok {
+		if markerOwner(e.Content) != o.Owner {
+			return fmt.Errorf("hover: %s %s in %s marks another owner: %w", e.Type, e.Name, domain, ErrNotOwned)
+		}
+		return nil
+	}
+	if why := o.refuses(e); why != "" {
+		return fmt.Errorf("hover: %s %s in %s %s: %w", e.Type, e.Name, domain, why, ErrNotOwned)
+	}
+	if o.Owner == "" {
+		return nil
+	}
+
+	markers, err := c.ListRecordsContext(ctx, domain, RecordFilter{Name: markerName(e.Name), Type: TypeTXT})
+	if err != nil {
+		return err
+	}
+	for _, m := range markers {
+		if markerOwner(m.Content) == o.Owner {
+			return nil
+		}
+	}
+	return fmt.Errorf("hover: %s %s in %s isn't marked as owned by %s: %w", e.Type, e.Name, domain, o.Owner, ErrNotOwned)
+}
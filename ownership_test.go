@@ -0,0 +1,137 @@
+package hoverdnsapi_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/chickenandpork/hoverdnsapi" // to ensure testing without extra access
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOwnershipDiff checks that Hover's records and ignored records are left alone
+func TestOwnershipDiff(t *testing.T) {
+	d := hoverdnsapi.Domain{DomainName: "example.com", Entries: []hoverdnsapi.Entry{
+		{ID: "1", Name: "@", Type: "A", Content: "192.0.2.1", Default: true},
+		{ID: "2", Name: "@", Type: "MX", Content: "10 mx.hover.com.cust.hostedemail.com", CanRevert: true},
+		{ID: "3", Name: "_acme-challenge.www", Type: "TXT", Content: "token"},
+		{ID: "4", Name: "old", Type: "A", Content: "192.0.2.4"},
+	}}
+	policy := hoverdnsapi.Ownership{Ignore: []hoverdnsapi.IgnoreRule{{Pattern: "^_acme-challenge", Type: "txt"}}}
+
+	actions, err := policy.Diff(d, []hoverdnsapi.Entry{
+		{Name: "@", Type: "A", Content: "192.0.2.1"}, // satisfied by Hover's own
+		{Name: "www", Type: "CNAME", Content: "example.com"},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"Delete old.example.com A 192.0.2.4 #4",
+			"Add www.example.com CNAME example.com",
+		}, describe(actions))
+	}
+
+	_, err = policy.Diff(d, []hoverdnsapi.Entry{{Name: "@", Type: "MX", Content: "10 mx.example.net"}})
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrNotOwned), "expected ErrNotOwned: %v", err)
+
+	_, err = hoverdnsapi.Ownership{Ignore: []hoverdnsapi.IgnoreRule{{}}}.Diff(d, nil)
+	assert.Error(t, err, "a rule matching everything")
+	_, err = hoverdnsapi.Ownership{Ignore: []hoverdnsapi.IgnoreRule{{Pattern: "("}}}.Diff(d, nil)
+	assert.Error(t, err, "a bad pattern")
+}
+
+// TestOwnershipMarkers checks that an owner only changes names it has marked, and marks the names
+// it adds
+func TestOwnershipMarkers(t *testing.T) {
+	srv, client := newTestClient(t, hoverdnsapi.WithOwnership(hoverdnsapi.Ownership{Owner: "team-a"}), hoverdnsapi.WithRateLimit(0, 0))
+	defer srv.Close()
+	assert.NoError(t, client.DoActions( // another team's name; adds aren't limited
+		hoverdnsapi.NewAction(hoverdnsapi.Add, "_hoverdns-owner.b.example.com", "example.com", "heritage=hoverdns,owner=team-b", 0),
+		hoverdnsapi.NewRecordAction(hoverdnsapi.Add, "b.example.com", "example.com", "A", "192.0.2.2", 0),
+	))
+
+	state := hoverdnsapi.DesiredState{Domain: "example.com", Records: []hoverdnsapi.Entry{
+		{Name: "api", Type: "A", Content: "192.0.2.10"},
+		{Name: "*", Type: "A", Content: "192.0.2.11"},
+	}}
+	actions, err := client.Plan("", state)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"Add _hoverdns-owner.api.example.com TXT heritage=hoverdns,owner=team-a",
+			"Add _hoverdns-owner._wildcard.example.com TXT heritage=hoverdns,owner=team-a",
+			"Add *.example.com A 192.0.2.11",
+			"Add api.example.com A 192.0.2.10",
+		}, describe(actions), "www and b aren't ours, so they stay")
+	}
+	assert.NoError(t, client.DoActions(actions...))
+
+	actions, err = client.Plan("", state)
+	if assert.NoError(t, err) {
+		assert.Empty(t, actions)
+	}
+
+	// dropping a name removes it and its marker
+	state.Records = state.Records[:1]
+	actions, err = client.Plan("", state)
+	if assert.NoError(t, err) && assert.Len(t, actions, 2) {
+		assert.Contains(t, describe(actions)[0], "Delete *.example.com A 192.0.2.11 #")
+		assert.Contains(t, describe(actions)[1], "Delete _hoverdns-owner._wildcard.example.com TXT")
+	}
+	assert.NoError(t, client.DoActions(actions...))
+	_, ok := findEntry(srv.Entries("example.com"), "_hoverdns-owner._wildcard", hoverdnsapi.TypeTXT)
+	assert.False(t, ok)
+
+	// names of others, or no one, can't be taken without adopting them
+	for _, e := range []hoverdnsapi.Entry{
+		{Name: "b", Type: "A", Content: "192.0.2.3"},
+		{Name: "www", Type: "CNAME", Content: "example.net"},
+	} {
+		_, err = client.Plan("", hoverdnsapi.DesiredState{Domain: "example.com", Records: []hoverdnsapi.Entry{e}})
+		assert.True(t, errors.Is(err, hoverdnsapi.ErrNotOwned), "expected ErrNotOwned for %s: %v", e.Name, err)
+	}
+
+	// nor deleted directly
+	err = client.DoActions(hoverdnsapi.NewRecordAction(hoverdnsapi.Delete, "www.example.com", "example.com", "", "", 0))
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrNotOwned), "expected ErrNotOwned: %v", err)
+	err = client.DoActions(hoverdnsapi.NewRecordAction(hoverdnsapi.Delete, "example.com", "example.com", "A", "", 0))
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrNotOwned), "expected ErrNotOwned: %v", err)
+	www, _ := findEntry(srv.Entries("example.com"), "www", hoverdnsapi.TypeCNAME)
+	err = client.DeleteRecordByID("example.com", www.ID)
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrNotOwned), "expected ErrNotOwned: %v", err)
+	_, ok = findEntry(srv.Entries("example.com"), "www", hoverdnsapi.TypeCNAME)
+	assert.True(t, ok)
+
+	// but ours can be
+	assert.NoError(t, client.DoActions(hoverdnsapi.NewRecordAction(hoverdnsapi.Delete, "api.example.com", "example.com", "A", "", 0)))
+}
+
+// TestOwnershipDeleteAll checks that a DeleteAll refused for any of its matches deletes none
+func TestOwnershipDeleteAll(t *testing.T) {
+	srv, client := newTestClient(t, hoverdnsapi.WithOwnership(hoverdnsapi.Ownership{Ignore: []hoverdnsapi.IgnoreRule{{Name: "www", Type: hoverdnsapi.TypeTXT}}}))
+	defer srv.Close()
+	assert.NoError(t, client.DoActions(
+		hoverdnsapi.NewRecordAction(hoverdnsapi.Add, "www.example.com", "example.com", "A", "192.0.2.2", 0),
+		hoverdnsapi.NewAction(hoverdnsapi.Add, "www.example.com", "example.com", "keep me", 0),
+	))
+	before := srv.Entries("example.com")
+
+	err := client.DoActions(hoverdnsapi.NewRecordAction(hoverdnsapi.DeleteAll, "www.example.com", "example.com", "", "", 0))
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrNotOwned), "expected ErrNotOwned: %v", err)
+	assert.Equal(t, before, srv.Entries("example.com"), "some matches deleted before one was refused")
+}
+
+// TestOwnershipAdopt checks that adopting claims unmarked names, but not those of others
+func TestOwnershipAdopt(t *testing.T) {
+	srv, client := newTestClient(t, hoverdnsapi.WithOwnership(hoverdnsapi.Ownership{Owner: "team-a", Adopt: true}))
+	defer srv.Close()
+
+	state := hoverdnsapi.DesiredState{Domain: "example.com", Records: []hoverdnsapi.Entry{
+		{Name: "www", Type: "CNAME", Content: "example.net"},
+	}}
+	actions, err := client.Plan("", state)
+	if assert.NoError(t, err) && assert.Len(t, actions, 2) {
+		assert.Equal(t, "Add _hoverdns-owner.www.example.com TXT heritage=hoverdns,owner=team-a", describe(actions)[0])
+	}
+	assert.NoError(t, client.DoActions(actions...))
+
+	www, _ := findEntry(srv.Entries("example.com"), "www", hoverdnsapi.TypeCNAME)
+	assert.Equal(t, "example.net", www.Content)
+}
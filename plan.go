@@ -0,0 +1,212 @@
+package hoverdnsapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DesiredState is the records that a domain should hold, such as kept in a file under version
+// control: Plan finds the Actions that bring Hover to it.  Records are Entries, of which only the
+// Name (relative, as Hover shows it, or absolute with the trailing dot), Type, Content, and TTL
+// are used; a TTL of zero takes Hover's default, or leaves an existing record's TTL alone.
+//
+//	domain: example.com
+//	records:
+//	  - {name: "@", type: A, content: 192.0.2.1, ttl: 3600}
+//	  - {name: www, type: CNAME, content: example.com}
+//	  - {name: "@", type: MX, content: 10 mx.example.com}
+//
+// Records matching any of the Ignore rules are left alone, as by the client's Ownership.
+type DesiredState struct {
+	Domain  string       `json:"domain" yaml:"domain"`
+	Records []Entry      `json:"records" yaml:"records"`
+	Ignore  []IgnoreRule `json:"ignore,omitempty" yaml:"ignore,omitempty"`
+}
+
+// ReadDesiredState reads a DesiredState from a YAML file, or a JSON file if named *.json.  Fields
+// that aren't part of the state are refused, to catch typos.
+func ReadDesiredState(filename string) (*DesiredState, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("hover: reading desired state: %w", err)
+	}
+
+	var s DesiredState
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		err = json.Unmarshal(data, &s)
+	} else {
+		err = yaml.UnmarshalStrict(data, &s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("hover: parsing desired state %s: %w", filename, err)
+	}
+	return &s, nil
+}
+
+// Diff compares the desired records with those that the domain, with its entries expanded,
+// holds, returning the Actions that bring the domain to the desired state: Deletes, then Updates,
+// then Adds, each in order of name and type.  Records are matched by name, type, and content;
+// where the content of a name and type differs, existing entries are updated to the desired
+// content before any are added or deleted, so that the name keeps its ID and is never without a
+// record.  Updates and Deletes carry the ID of the entry they act upon (see WithEntryID).  Entries
+// that Hover manages (Entry.Default or Entry.CanRevert) may be updated in place, but are never
+// deleted.
+//
+// A desired record of a type, or with a TTL, that Hover wouldn't accept is ErrUnactionable.
+func Diff(d Domain, desired []Entry) ([]Action, error) {
+	want := make(map[string][]Entry)
+	for _, e := range desired {
+		e, err := desiredEntry(d, e)
+		if err != nil {
+			return nil, err
+		}
+		want[planKey(e)] = append(want[planKey(e)], e)
+	}
+	have := make(map[string][]Entry)
+	for _, e := range d.Entries {
+		have[planKey(e)] = append(have[planKey(e)], e)
+	}
+
+	keys := make([]string, 0, len(want)+len(have))
+	for k := range want {
+		keys = append(keys, k)
+	}
+	for k := range have {
+		if _, ok := want[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var deletes, updates, adds []Action
+	for _, k := range keys {
+		del, upd, add := diffSet(d, want[k], have[k])
+		deletes, updates, adds = append(deletes, del...), append(updates, upd...), append(adds, add...)
+	}
+	return append(append(deletes, updates...), adds...), nil
+}
+
+// diffSet finds the actions that bring the existing entries of a name and type to those desired
+func diffSet(d Domain, want, have []Entry) (deletes, updates, adds []Action) {
+	used := make([]bool, len(have))
+	var missing []Entry
+
+outer:
+	for n, w := range want {
+		for _, prev := range want[:n] {
+			if prev.Content == w.Content {
+				continue outer // listed twice
+			}
+		}
+		for i, h := range have {
+			if !used[i] && h.Content == w.Content {
+				used[i] = true
+				if w.TTL != 0 && w.TTL != h.TTL {
+					updates = append(updates, planAction(Update, d, w).WithEntryID(h.ID))
+				}
+				continue outer
+			}
+		}
+		missing = append(missing, w)
+	}
+
+	for i, h := range have {
+		if used[i] {
+			continue
+		}
+		if len(missing) > 0 {
+			updates = append(updates, planAction(Update, d, missing[0]).WithEntryID(h.ID))
+			missing = missing[1:]
+			continue
+		}
+		if !hoverManaged(h) {
+			deletes = append(deletes, planAction(Delete, d, h).WithEntryID(h.ID))
+		}
+	}
+
+	for _, w := range missing {
+		adds = append(adds, planAction(Add, d, w))
+	}
+	return deletes, updates, adds
+}
+
+// desiredEntry checks a desired record, and puts its name and type in the form Hover uses
+func desiredEntry(d Domain, e Entry) (Entry, error) {
+	name := strings.ToLower(e.Name)
+	switch {
+	case name == "":
+		name = "@"
+	case strings.HasSuffix(name, "."):
+		hostname, ok := d.hostname(name)
+		if !ok {
+			return Entry{}, fmt.Errorf("hover: desired record %s is outside %s: %w", e.Name, d.DomainName, ErrUnactionable)
+		}
+		name = hostname
+	}
+	e.Name, e.Type = name, strings.ToUpper(e.Type)
+
+	if !ValidRecordType(e.Type) {
+		return Entry{}, fmt.Errorf("hover: desired record %s has type %q, which Hover doesn't accept: %w", e.Name, e.Type, ErrUnactionable)
+	}
+	if e.TTL < 0 {
+		return Entry{}, fmt.Errorf("hover: desired record %s %s has a negative TTL: %w", e.Name, e.Type, ErrUnactionable)
+	}
+	return e, planAction(Add, d, e).checkTTL()
+}
+
+// planKey groups entries by name and type
+func planKey(e Entry) string {
+	return strings.ToLower(e.Name) + " " + strings.ToUpper(e.Type)
+}
+
+// planAction is the action of the entry within the domain
+func planAction(act HoverAct, d Domain, e Entry) Action {
+	fqdn := d.DomainName
+	if e.Name != "@" && e.Name != "" {
+		fqdn = e.Name + "." + d.DomainName
+	}
+	return NewRecordAction(act, fqdn, d.DomainName, e.Type, e.Content, uint(e.TTL))
+}
+
+// Plan reads the domain of the desired state from Hover, and returns the Actions that bring it to
+// the desired state; see Diff, and Ownership.Diff if the client has an Ownership policy or the
+// state has Ignore rules.  The domain is that of the state, or the one given if the state names
+// none.  Carry the actions out with DoActions.
+func (c *Client) Plan(domain string, state DesiredState) ([]Action, error) {
+	return c.PlanContext(context.Background(), domain, state)
+}
+
+// PlanContext is Plan bound to a context for cancellation and deadlines
+func (c *Client) PlanContext(ctx context.Context, domain string, state DesiredState) ([]Action, error) {
+	if state.Domain != "" {
+		if domain != "" && !strings.EqualFold(strings.TrimSuffix(domain, "."), strings.TrimSuffix(state.Domain, ".")) {
+			return nil, fmt.Errorf("hover: desired state is of %s, not %s: %w", state.Domain, domain, ErrUnactionable)
+		}
+		domain = state.Domain
+	}
+
+	d, err := c.GetDomainContext(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	if d.Entries, err = c.ListRecordsContext(ctx, d.DomainName, RecordFilter{}); err != nil {
+		return nil, err
+	}
+
+	if c.ownership == nil && len(state.Ignore) == 0 {
+		return Diff(d, state.Records)
+	}
+	var o Ownership
+	if c.ownership != nil {
+		o = *c.ownership
+	}
+	o.Ignore = append(append([]IgnoreRule(nil), o.Ignore...), state.Ignore...)
+	return o.Diff(d, state.Records)
+}
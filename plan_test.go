@@ -0,0 +1,171 @@
+package hoverdnsapi_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/chickenandpork/hoverdnsapi" // to ensure testing without extra access
+	"github.com/stretchr/testify/assert"
+)
+
+// describe renders actions compactly for comparison
+func describe(actions []hoverdnsapi.Action) []string {
+	out := make([]string, 0, len(actions))
+	for _, a := range actions {
+		s := a.Act().String() + " " + a.FQDN() + " " + a.Type() + " " + a.Content()
+		if a.EntryID() != "" {
+			s += " #" + a.EntryID()
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// TestDiff checks the actions found for each kind of difference
+func TestDiff(t *testing.T) {
+	d := hoverdnsapi.Domain{DomainName: "example.com", Entries: []hoverdnsapi.Entry{
+		{ID: "1", Name: "@", Type: "A", Content: "192.0.2.1", TTL: 900},
+		{ID: "2", Name: "www", Type: "CNAME", Content: "example.com", TTL: 900},
+		{ID: "3", Name: "rr", Type: "A", Content: "192.0.2.10", TTL: 900},
+		{ID: "4", Name: "rr", Type: "A", Content: "192.0.2.11", TTL: 900},
+		{ID: "5", Name: "old", Type: "TXT", Content: "gone", TTL: 900},
+	}}
+
+	actions, err := hoverdnsapi.Diff(d, []hoverdnsapi.Entry{
+		{Name: "", Type: "a", Content: "192.0.2.1"},                                  // unchanged: no TTL given
+		{Name: "www.example.com.", Type: "CNAME", Content: "example.com", TTL: 3600}, // TTL only
+		{Name: "rr", Type: "A", Content: "192.0.2.11"},
+		{Name: "rr", Type: "A", Content: "192.0.2.12"}, // takes the place of .10
+		{Name: "rr", Type: "A", Content: "192.0.2.13"},
+		{Name: "rr", Type: "A", Content: "192.0.2.13"}, // listed twice
+		{Name: "new", Type: "TXT", Content: "hello", TTL: 300},
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"Delete old.example.com TXT gone #5",
+			"Update rr.example.com A 192.0.2.12 #3",
+			"Update www.example.com CNAME example.com #2",
+			"Add new.example.com TXT hello",
+			"Add rr.example.com A 192.0.2.13",
+		}, describe(actions))
+	}
+
+	// the desired state, reached, has nothing to do
+	actions, err = hoverdnsapi.Diff(d, d.Entries)
+	if assert.NoError(t, err) {
+		assert.Empty(t, actions)
+	}
+
+	for _, bad := range []hoverdnsapi.Entry{
+		{Name: "x", Type: "PTR", Content: "host.example.com"},
+		{Name: "x", Type: "A", Content: "192.0.2.1", TTL: 60},
+		{Name: "x.example.org.", Type: "A", Content: "192.0.2.1"},
+	} {
+		_, err := hoverdnsapi.Diff(d, []hoverdnsapi.Entry{bad})
+		assert.True(t, errors.Is(err, hoverdnsapi.ErrUnactionable), "expected ErrUnactionable for %+v: %v", bad, err)
+	}
+}
+
+// TestPlanHoverManaged checks that, with no ownership policy, the records that Hover manages
+// aren't deleted by a desired state that omits them, nor by a delete
+func TestPlanHoverManaged(t *testing.T) {
+	srv, client := newTestClient(t, hoverdnsapi.WithRateLimit(0, 0))
+	defer srv.Close()
+	srv.AddDomain(hoverdnsapi.Domain{DomainName: "example.net", Entries: []hoverdnsapi.Entry{
+		{Name: "@", Type: "A", Content: "192.0.2.1", Default: true},
+		{Name: "*", Type: "A", Content: "192.0.2.1", Default: true},
+		{Name: "@", Type: "MX", Content: "10 mx.hover.com.cust.hostedemail.com", CanRevert: true},
+		{Name: "old", Type: "TXT", Content: "mine"},
+	}})
+
+	actions, err := client.Plan("example.net", hoverdnsapi.DesiredState{Records: []hoverdnsapi.Entry{
+		{Name: "www", Type: "CNAME", Content: "example.com"},
+	}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"Delete old.example.net TXT mine #" + srv.Entries("example.net")[3].ID,
+			"Add www.example.net CNAME example.com",
+		}, describe(actions))
+	}
+	assert.NoError(t, client.DoActions(actions...))
+	assert.Len(t, srv.Entries("example.net"), 4)
+
+	err = client.DoActions(hoverdnsapi.NewRecordAction(hoverdnsapi.Delete, "*.example.net", "example.net", "A", "", 0))
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrNotOwned), "expected ErrNotOwned: %v", err)
+	err = client.DeleteRecordByID("example.net", srv.Entries("example.net")[2].ID)
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrNotOwned), "expected ErrNotOwned: %v", err)
+	_, ok := findEntry(srv.Entries("example.net"), "@", "MX")
+	assert.True(t, ok, "Hover's MX deleted")
+	assert.Len(t, srv.Entries("example.net"), 4)
+}
+
+// TestPlanApply checks a plan against the fake, and that applying it reaches the desired state
+func TestPlanApply(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "plan")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "example.com.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`domain: example.com
+records:
+  - {name: "@", type: A, content: 192.0.2.2, ttl: 3600}
+  - name: mail
+    type: MX
+    content: 10 mx.example.net
+`), 0600))
+
+	state, err := hoverdnsapi.ReadDesiredState(file)
+	if !assert.NoError(t, err) {
+		return
+	}
+	id := srv.Entries("example.com")[1].ID // www
+
+	actions, err := client.Plan("", *state)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{
+			"Delete www.example.com CNAME example.com #" + id,
+			"Update example.com A 192.0.2.2 #" + srv.Entries("example.com")[0].ID,
+			"Add mail.example.com MX 10 mx.example.net",
+		}, describe(actions))
+	}
+	assert.NoError(t, client.DoActions(actions...))
+
+	entries := srv.Entries("example.com")
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "192.0.2.2", entries[0].Content)
+		assert.Equal(t, 3600, entries[0].TTL)
+		assert.Equal(t, "10 mx.example.net", entries[1].Content)
+	}
+
+	actions, err = client.Plan("example.com", *state)
+	if assert.NoError(t, err) {
+		assert.Empty(t, actions, "nothing left to do once applied")
+	}
+
+	// a plan gone stale: the entry to update has since been deleted
+	stale := hoverdnsapi.NewRecordAction(hoverdnsapi.Update, "www.example.com", "example.com", "CNAME", "example.net", 0).WithEntryID(id)
+	err = client.DoActions(stale)
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrRecordNotFound), "expected ErrRecordNotFound: %v", err)
+
+	_, err = client.Plan("example.org", *state)
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrUnactionable), "expected ErrUnactionable: %v", err)
+
+	// typos are refused rather than ignored
+	assert.NoError(t, ioutil.WriteFile(file, []byte("records:\n  - {name: www, type: A, contnet: 192.0.2.1}\n"), 0600))
+	_, err = hoverdnsapi.ReadDesiredState(file)
+	assert.Error(t, err)
+
+	json := filepath.Join(dir, "example.com.json")
+	assert.NoError(t, ioutil.WriteFile(json, []byte(`{"domain": "example.com", "records": [{"name": "www", "type": "A", "content": "192.0.2.1"}]}`), 0600))
+	state, err = hoverdnsapi.ReadDesiredState(json)
+	if assert.NoError(t, err) && assert.Len(t, state.Records, 1) {
+		assert.Equal(t, "192.0.2.1", state.Records[0].Content)
+	}
+}
@@ -0,0 +1,144 @@
+package hoverdnsapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit describes a token bucket limiting the requests a Client sends: Rate requests per
+// second on average, with bursts of up to Burst.  A Rate of zero doesn't limit requests, but a
+// Retry-After from Hover is still honoured.
+type RateLimit struct {
+	Rate  float64 // requests per second
+	Burst int     // requests that can be sent back-to-back after idling
+}
+
+var (
+	// DefaultRateLimit keeps bulk operations polite enough to avoid Hover locking us out
+	DefaultRateLimit = RateLimit{Rate: 5, Burst: 10}
+
+	// NoRateLimit sends requests as quickly as they're made
+	NoRateLimit = RateLimit{}
+)
+
+// Stats counts the requests made by a Client, and the time spent holding them back
+type Stats struct {
+	Requests      int64         // HTTP requests sent, including retries
+	Retries       int64         // requests that were repeats of a failed attempt
+	Throttled     int64         // 429 Too Many Requests responses received
+	RateLimitWait time.Duration // time spent waiting on the rate limit or a Retry-After
+}
+
+// rateLimiter is a token bucket that can also be paused, such as by a Retry-After
+type rateLimiter struct {
+	mu          sync.Mutex
+	limit       RateLimit
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{limit: limit, tokens: float64(limit.Burst), last: time.Now()}
+}
+
+// reserve takes a token if one is available, returning zero; otherwise, it returns how long to
+// wait before trying again
+func (r *rateLimiter) reserve(now time.Time) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Before(r.pausedUntil) {
+		return r.pausedUntil.Sub(now)
+	}
+	if r.limit.Rate <= 0 {
+		return 0
+	}
+
+	r.tokens += now.Sub(r.last).Seconds() * r.limit.Rate
+	if r.tokens > float64(r.limit.Burst) {
+		r.tokens = float64(r.limit.Burst)
+	}
+	r.last = now
+
+	if r.tokens >= 1 {
+		r.tokens--
+		return 0
+	}
+	return time.Duration((1 - r.tokens) / r.limit.Rate * float64(time.Second))
+}
+
+// wait blocks until a request may be sent, or the context is done, returning the time waited
+func (r *rateLimiter) wait(ctx context.Context) (waited time.Duration, err error) {
+	for {
+		delay := r.reserve(time.Now())
+		if delay <= 0 {
+			return waited, nil
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return waited, err
+		}
+		waited += delay
+	}
+}
+
+// pause holds off every request until the given time
+func (r *rateLimiter) pause(until time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if until.After(r.pausedUntil) {
+		r.pausedUntil = until
+	}
+}
+
+// retryAfter parses the Retry-After header of a response, which is either a number of seconds or
+// an HTTP date; zero if absent or unparseable
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	header := resp.Header.Get("Retry-After")
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(header); err == nil && when.After(now) {
+		return when.Sub(now)
+	}
+	return 0
+}
+
+// WithRateLimit limits the requests sent to rate per second, in bursts of up to burst; a rate of
+// zero removes the limit
+func WithRateLimit(rate float64, burst int) Option {
+	return func(c *Client) error {
+		if rate < 0 {
+			return fmt.Errorf("hover: WithRateLimit: negative rate %f", rate)
+		}
+		if rate > 0 && burst < 1 {
+			return fmt.Errorf("hover: WithRateLimit: burst of %d would never send", burst)
+		}
+		c.limiter = newRateLimiter(RateLimit{Rate: rate, Burst: burst})
+		return nil
+	}
+}
+
+// Stats returns the counts of requests made by the client so far
+func (c *Client) Stats() Stats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	return c.stats
+}
+
+// count updates the stats of the client under lock
+func (c *Client) count(update func(*Stats)) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	update(&c.stats)
+}
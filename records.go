@@ -0,0 +1,334 @@
+package hoverdnsapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RecordFilter narrows the entries returned by ListRecords; a blank field matches anything
+type RecordFilter struct {
+	Name    string // relative name as Hover shows it (ie "www", "@"), or an FQDN (ie "www.example.com")
+	Type    string // record type, ie TypeTXT
+	Content string // content exactly as Hover shows it in Entry.Content
+}
+
+// matches reports whether the entry of the domain passes the filter
+func (f RecordFilter) matches(d Domain, e Entry) bool {
+	if f.Type != "" && !strings.EqualFold(f.Type, e.Type) {
+		return false
+	}
+	if f.Content != "" && f.Content != e.Content {
+		return false
+	}
+	if f.Name == "" {
+		return true
+	}
+
+	name := strings.TrimSuffix(f.Name, ".")
+	if hostname, ok := d.hostname(name); ok {
+		name = hostname
+	}
+	return strings.EqualFold(name, e.Name)
+}
+
+// ListRecords returns the entries of a domain, given by name or ID, that pass the filter.  The
+// entries are read from Hover if not already cached.
+func (c *Client) ListRecords(domain string, filter RecordFilter) ([]Entry, error) {
+	return c.ListRecordsContext(context.Background(), domain, filter)
+}
+
+// ListRecordsContext is ListRecords bound to a context for cancellation and deadlines
+func (c *Client) ListRecordsContext(ctx context.Context, domain string, filter RecordFilter) ([]Entry, error) {
+	d, err := c.GetDomainContext(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.entriesFresh(d.DomainName) {
+		if err := c.GetDomainEntriesContext(ctx, d.DomainName); err != nil {
+			return nil, err
+		}
+		if d, err = c.GetDomainContext(ctx, d.DomainName); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make([]Entry, 0)
+	for _, e := range d.Entries {
+		if filter.matches(d, e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// Record is a DNS record to create or update by CreateRecord and UpdateRecord.  Hover reports it
+// back as an Entry, which carries the ID that Hover assigned.
+type Record struct {
+	Name     string // relative name (ie "www", or "@" for the domain itself), or an FQDN within the domain
+	Type     string // record type, ie TypeA
+	Value    string // value of the record; for MX and SRV, see Priority, Weight, and Port
+	Priority uint   // MX and SRV priority
+	Weight   uint   // SRV weight
+	Port     uint   // SRV port
+	TTL      uint   // seconds, one of ValidTTLs; zero leaves Hover to use its default
+}
+
+// fqdn is the fully-qualified name of the record within the domain
+func (r Record) fqdn(d Domain) string {
+	name := strings.TrimSuffix(r.Name, ".")
+	switch {
+	case name == "" || name == "@" || strings.EqualFold(name, d.DomainName):
+		return d.DomainName
+	case strings.HasSuffix(strings.ToLower(name), "."+strings.ToLower(d.DomainName)):
+		return name
+	}
+	return name + "." + d.DomainName
+}
+
+// action expresses the record as an Action upon the domain, so that it's formatted and sent
+// exactly as DoActions would
+func (r Record) action(act HoverAct, d Domain) (Action, error) {
+	if !ValidRecordType(r.Type) {
+		return Action{}, fmt.Errorf("hover: unsupported record type %q: %w", r.Type, ErrUnactionable)
+	}
+	return NewRecordAction(act, r.fqdn(d), d.DomainName, r.Type, r.Value, r.TTL).WithPriority(r.Priority).WithWeight(r.Weight).WithPort(r.Port), nil
+}
+
+// GetRecord returns a single entry of the domain (by name or ID) by the entry's ID.  A missing
+// entry is reported as ErrRecordNotFound.
+func (c *Client) GetRecord(domain, id string) (Entry, error) {
+	return c.GetRecordContext(context.Background(), domain, id)
+}
+
+// GetRecordContext is GetRecord bound to a context for cancellation and deadlines
+func (c *Client) GetRecordContext(ctx context.Context, domain, id string) (Entry, error) {
+	for attempt := 1; attempt <= 2; attempt++ {
+		entries, err := c.ListRecordsContext(ctx, domain, RecordFilter{})
+		if err != nil {
+			return Entry{}, err
+		}
+		for _, e := range entries {
+			if e.ID == id {
+				return e, nil
+			}
+		}
+
+		// the cache may predate the record; check with Hover before giving up
+		if d, err := c.GetDomainContext(ctx, domain); err == nil {
+			c.InvalidateDomain(d.DomainName)
+		}
+	}
+	return Entry{}, fmt.Errorf("hover: record %s of %s: %w", id, domain, ErrRecordNotFound)
+}
+
+// CreateRecord adds a record to the domain (by name or ID), returning the entry created with the
+// ID Hover assigned to it, and the TTL in effect
+func (c *Client) CreateRecord(domain string, r Record) (Entry, error) {
+	return c.CreateRecordContext(context.Background(), domain, r)
+}
+
+// CreateRecordContext is CreateRecord bound to a context for cancellation and deadlines
+func (c *Client) CreateRecordContext(ctx context.Context, domain string, r Record) (Entry, error) {
+	d, err := c.GetDomainContext(ctx, domain)
+	if err != nil {
+		return Entry{}, err
+	}
+	a, err := r.action(Add, d)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	created, err := c.addEntry(ctx, &d, a)
+	c.InvalidateDomain(d.DomainName)
+	if err != nil || created.ID != "" {
+		return created, err
+	}
+	return c.findRecord(ctx, d, a)
+}
+
+// UpdateRecord changes the value (and the TTL, if given) of the entry of the domain (by name or ID) with the given ID,
+// returning the entry as updated.  The name and type of a record can't be changed; leave them
+// blank in r, or the same as the entry.  If Hover refuses to update the entry in place, it's
+// deleted and added anew, so the ID of the entry returned is the one to track from then on.
+func (c *Client) UpdateRecord(domain, id string, r Record) (Entry, error) {
+	return c.UpdateRecordContext(context.Background(), domain, id, r)
+}
+
+// UpdateRecordContext is UpdateRecord bound to a context for cancellation and deadlines
+func (c *Client) UpdateRecordContext(ctx context.Context, domain, id string, r Record) (Entry, error) {
+	existing, err := c.GetRecordContext(ctx, domain, id)
+	if err != nil {
+		return Entry{}, err
+	}
+	d, err := c.GetDomainContext(ctx, domain)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if r.Name == "" {
+		r.Name = existing.Name
+	}
+	if r.Type == "" {
+		r.Type = existing.Type
+	}
+	a, err := r.action(Update, d)
+	if err != nil {
+		return Entry{}, err
+	}
+	if hostname, _ := d.hostname(a.fqdn); !strings.EqualFold(hostname, existing.Name) || !strings.EqualFold(a.rrtype, existing.Type) {
+		return Entry{}, fmt.Errorf("hover: record %s is %s %s; its name and type can't be changed to %s %s: %w", id, existing.Type, existing.Name, a.rrtype, hostname, ErrUnactionable)
+	}
+
+	defer c.InvalidateDomain(d.DomainName)
+	return c.reviseEntry(ctx, d, existing, a)
+}
+
+// DeleteRecordByID removes the entry of the domain (by name or ID) with the given ID.  A missing
+// entry is reported as ErrRecordNotFound.
+func (c *Client) DeleteRecordByID(domain, id string) error {
+	return c.DeleteRecordByIDContext(context.Background(), domain, id)
+}
+
+// DeleteRecordByIDContext is DeleteRecordByID bound to a context for cancellation and deadlines
+func (c *Client) DeleteRecordByIDContext(ctx context.Context, domain, id string) error {
+	existing, err := c.GetRecordContext(ctx, domain, id)
+	if err != nil {
+		return err
+	}
+	d, err := c.GetDomainContext(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	err = c.deleteEntry(ctx, &d, existing)
+	c.InvalidateDomain(d.DomainName)
+	return err
+}
+
+// findRecord re-reads the domain to find the entry that an action created, for when Hover didn't
+// echo it back
+func (c *Client) findRecord(ctx context.Context, d Domain, a Action) (Entry, error) {
+	entries, err := c.ListRecordsContext(ctx, d.DomainName, RecordFilter{Name: a.fqdn, Type: a.rrtype})
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, e := range entries {
+		if e.Content == a.Content() {
+			return e, nil
+		}
+	}
+	return Entry{}, fmt.Errorf("hover: %s %s of %s not found after change: %w", a.rrtype, a.fqdn, d.DomainName, ErrRecordNotFound)
+}
+
+// UpsertMode chooses how UpsertRecord treats other records of the same name and type (the RRset)
+type UpsertMode int
+
+const (
+	// UpsertReplace makes the record the only one of its name and type, updating or deleting
+	// any others
+	UpsertReplace UpsertMode = iota
+	// UpsertAppend adds the record alongside any others of its name and type, unless it's
+	// already among them
+	UpsertAppend
+)
+
+// String of course gives a string representation of the UpsertMode
+func (m UpsertMode) String() string {
+	switch m {
+	case UpsertReplace:
+		return "replace"
+	case UpsertAppend:
+		return "append"
+	}
+
+	return "(error) UpsertMode const extended without String() equivalent"
+}
+
+// UpsertRecord ensures that the record exists in the domain (by name or ID), returning the entry
+// that holds it.  Other records of the same name and type are replaced or kept according to the
+// mode; an existing record of the same value is kept (its TTL updated if need be), so repeating
+// an upsert changes nothing.
+func (c *Client) UpsertRecord(domain string, r Record, mode UpsertMode) (Entry, error) {
+	return c.UpsertRecordContext(context.Background(), domain, r, mode)
+}
+
+// UpsertRecordContext is UpsertRecord bound to a context for cancellation and deadlines
+func (c *Client) UpsertRecordContext(ctx context.Context, domain string, r Record, mode UpsertMode) (Entry, error) {
+	d, err := c.GetDomainContext(ctx, domain)
+	if err != nil {
+		return Entry{}, err
+	}
+	a, err := r.action(Update, d)
+	if err != nil {
+		return Entry{}, err
+	}
+	existing, err := c.ListRecordsContext(ctx, d.DomainName, RecordFilter{Name: a.fqdn, Type: a.rrtype})
+	if err != nil {
+		return Entry{}, err
+	}
+
+	var kept *Entry
+	others := make([]Entry, 0, len(existing))
+	for n, e := range existing {
+		if kept == nil && e.Content == a.Content() {
+			kept = &existing[n]
+		} else {
+			others = append(others, e)
+		}
+	}
+
+	defer c.InvalidateDomain(d.DomainName)
+	if kept != nil && r.TTL != 0 && uint(kept.TTL) != r.TTL {
+		updated, err := c.reviseEntry(ctx, d, *kept, a)
+		if err != nil {
+			return Entry{}, err
+		}
+		kept = &updated
+	}
+
+	switch mode {
+	case UpsertAppend:
+		if kept != nil {
+			return *kept, nil
+		}
+		return c.CreateRecordContext(ctx, d.DomainName, r)
+	case UpsertReplace:
+	default:
+		return Entry{}, fmt.Errorf("hover: unknown upsert mode %d", mode)
+	}
+
+	if kept == nil {
+		if len(others) == 0 {
+			return c.CreateRecordContext(ctx, d.DomainName, r)
+		}
+		// reuse one of the others, so that the name never goes without a record
+		updated, err := c.reviseEntry(ctx, d, others[0], a)
+		if err != nil {
+			return Entry{}, err
+		}
+		kept, others = &updated, others[1:]
+	}
+
+	for _, e := range others {
+		if err := c.deleteEntry(ctx, &d, e); err != nil {
+			return *kept, err
+		}
+	}
+	return *kept, nil
+}
+
+// reviseEntry updates the entry to match the action, returning the entry as it ends up
+func (c *Client) reviseEntry(ctx context.Context, d Domain, e Entry, a Action) (Entry, error) {
+	updated, strategy, err := c.replaceEntry(ctx, &d, e, a)
+	if err != nil {
+		return Entry{}, err
+	}
+	c.log.Printf("hover: record %s of %s updated by %s", e.ID, d.DomainName, strategy)
+	if updated.ID == "" { // replaced, but not echoed back
+		c.InvalidateDomain(d.DomainName)
+		return c.findRecord(ctx, d, a)
+	}
+	return updated, nil
+}
@@ -0,0 +1,313 @@
+package hoverdnsapi_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/chickenandpork/hoverdnsapi" // to ensure testing without extra access
+	"github.com/chickenandpork/hoverdnsapi/hovertest"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestListDomains checks the domain list and lookups by name and ID, without filling first
+func TestListDomains(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+	srv.AddDomain(hoverdnsapi.Domain{DomainName: "example.org"})
+
+	domains, err := client.ListDomains()
+	if assert.NoError(t, err) && assert.Len(t, domains, 2) {
+		assert.Equal(t, "example.com", domains[0].DomainName)
+		assert.Equal(t, "example.org", domains[1].DomainName)
+	}
+
+	d, err := client.GetDomain("example.org.")
+	if assert.NoError(t, err) {
+		assert.Equal(t, mustDomainID(t, srv, "example.org"), d.ID)
+	}
+	d, err = client.GetDomain(mustDomainID(t, srv, "example.com"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "example.com", d.DomainName)
+	}
+
+	_, err = client.GetDomain("example.net")
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrDomainNotFound), "expected ErrDomainNotFound: %v", err)
+}
+
+// TestListRecords checks the entries of a domain, filtered by name and type
+func TestListRecords(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		filter   hoverdnsapi.RecordFilter
+		expected []string
+	}{
+		{filter: hoverdnsapi.RecordFilter{}, expected: []string{"@", "www"}},
+		{filter: hoverdnsapi.RecordFilter{Name: "www"}, expected: []string{"www"}},
+		{filter: hoverdnsapi.RecordFilter{Name: "www.example.com."}, expected: []string{"www"}},
+		{filter: hoverdnsapi.RecordFilter{Name: "example.com"}, expected: []string{"@"}},
+		{filter: hoverdnsapi.RecordFilter{Type: "a"}, expected: []string{"@"}},
+		{filter: hoverdnsapi.RecordFilter{Name: "@", Type: hoverdnsapi.TypeCNAME}, expected: []string{}},
+	} {
+		entries, err := client.ListRecords("example.com", tc.filter)
+		if assert.NoError(t, err, "filter %+v", tc.filter) {
+			names := make([]string, 0)
+			for _, e := range entries {
+				names = append(names, e.Name)
+			}
+			assert.Equal(t, tc.expected, names, "filter %+v", tc.filter)
+		}
+	}
+
+	_, err := client.ListRecords("example.net", hoverdnsapi.RecordFilter{})
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrDomainNotFound), "expected ErrDomainNotFound: %v", err)
+}
+
+// TestRecordCRUD checks creating, reading, updating, and deleting a record by its ID
+func TestRecordCRUD(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+
+	created, err := client.CreateRecord("example.com", hoverdnsapi.Record{Name: "mail", Type: hoverdnsapi.TypeMX, Value: "mx.example.net", Priority: 10})
+	if !assert.NoError(t, err) || !assert.NotEmpty(t, created.ID) {
+		return
+	}
+	assert.Equal(t, "mail", created.Name)
+	assert.Equal(t, "10 mx.example.net", created.Content)
+
+	got, err := client.GetRecord("example.com", created.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, created, got)
+	}
+
+	updated, err := client.UpdateRecord("example.com", created.ID, hoverdnsapi.Record{Value: "mx2.example.net", Priority: 20})
+	if assert.NoError(t, err) {
+		assert.Equal(t, created.ID, updated.ID, "in-place update changed the ID")
+		assert.Equal(t, "20 mx2.example.net", updated.Content)
+	}
+	e, ok := findEntry(srv.Entries("example.com"), "mail", hoverdnsapi.TypeMX)
+	if assert.True(t, ok) {
+		assert.Equal(t, "20 mx2.example.net", e.Content)
+	}
+
+	_, err = client.UpdateRecord("example.com", created.ID, hoverdnsapi.Record{Name: "smtp", Value: "mx3.example.net"})
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrUnactionable), "rename: expected ErrUnactionable: %v", err)
+
+	assert.NoError(t, client.DeleteRecordByID("example.com", created.ID))
+	_, ok = findEntry(srv.Entries("example.com"), "mail", hoverdnsapi.TypeMX)
+	assert.False(t, ok, "record not deleted")
+
+	_, err = client.GetRecord("example.com", created.ID)
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrRecordNotFound), "expected ErrRecordNotFound: %v", err)
+	err = client.DeleteRecordByID("example.com", created.ID)
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrRecordNotFound), "expected ErrRecordNotFound: %v", err)
+}
+
+// TestRecordIDs checks that IDs are found even when Hover doesn't echo the entry, or replaces it
+func TestRecordIDs(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+
+	// created, but only {"succeeded":true} comes back
+	srv.Inject(hovertest.Fault{Method: http.MethodPost, Path: "/api/domains/", Status: http.StatusOK, Body: `{"succeeded":true}`, Lost: true, Times: 1})
+	created, err := client.CreateRecord("example.com", hoverdnsapi.Record{Name: "api.example.com", Type: "a", Value: "192.0.2.10"})
+	if assert.NoError(t, err) {
+		e, _ := findEntry(srv.Entries("example.com"), "api", hoverdnsapi.TypeA)
+		assert.Equal(t, e.ID, created.ID)
+	}
+
+	// an in-place update refused: the record is replaced, with a new ID
+	srv.Inject(hovertest.Fault{Method: http.MethodPut, Path: "/api/dns/", Status: http.StatusUnprocessableEntity, Times: 1})
+	updated, err := client.UpdateRecord("example.com", created.ID, hoverdnsapi.Record{Value: "192.0.2.11"})
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, created.ID, updated.ID)
+		e, _ := findEntry(srv.Entries("example.com"), "api", hoverdnsapi.TypeA)
+		assert.Equal(t, e.ID, updated.ID)
+		assert.Equal(t, "192.0.2.11", e.Content)
+	}
+}
+
+// TestSharedNames checks Delete, Update, and UpsertRecord when records share a name
+func TestSharedNames(t *testing.T) {
+	setup := func(t *testing.T) (*hovertest.Server, *hoverdnsapi.Client) {
+		srv, client := newTestClient(t)
+		for _, value := range []string{"wildcard-token", "apex-token"} {
+			if _, err := client.CreateRecord("example.com", hoverdnsapi.Record{Name: "_acme-challenge", Type: hoverdnsapi.TypeTXT, Value: value}); err != nil {
+				srv.Close()
+				t.Fatal(err)
+			}
+		}
+		return srv, client
+	}
+	values := func(srv *hovertest.Server) []string {
+		found := make([]string, 0)
+		for _, e := range srv.Entries("example.com") {
+			if e.Name == "_acme-challenge" {
+				found = append(found, e.Content)
+			}
+		}
+		return found
+	}
+
+	t.Run("delete one by value", func(t *testing.T) {
+		srv, client := setup(t)
+		defer srv.Close()
+
+		assert.NoError(t, client.DoActions(hoverdnsapi.NewAction(hoverdnsapi.Delete, "_acme-challenge.example.com", "example.com", "apex-token", 0)))
+		assert.Equal(t, []string{"wildcard-token"}, values(srv))
+	})
+
+	t.Run("delete ambiguous", func(t *testing.T) {
+		srv, client := setup(t)
+		defer srv.Close()
+
+		err := client.DoActions(hoverdnsapi.NewAction(hoverdnsapi.Delete, "_acme-challenge.example.com", "example.com", "", 0))
+		assert.True(t, errors.Is(err, hoverdnsapi.ErrAmbiguous), "expected ErrAmbiguous: %v", err)
+		err = client.Delete("_acme-challenge.example.com", "example.com")
+		assert.True(t, errors.Is(err, hoverdnsapi.ErrAmbiguous), "expected ErrAmbiguous: %v", err)
+		assert.Len(t, values(srv), 2)
+	})
+
+	t.Run("delete value", func(t *testing.T) {
+		srv, client := setup(t)
+		defer srv.Close()
+
+		assert.NoError(t, client.DeleteValue("_acme-challenge.example.com", "example.com", "wildcard-token"))
+		assert.Equal(t, []string{"apex-token"}, values(srv))
+		assert.NoError(t, client.DeleteValue("_acme-challenge.example.com", "example.com", "wildcard-token"), "already gone")
+		assert.NoError(t, client.DeleteValue("_acme-challenge.example.com", "example.com", "apex-token"))
+		assert.Len(t, values(srv), 0)
+	})
+
+	t.Run("delete all", func(t *testing.T) {
+		srv, client := setup(t)
+		defer srv.Close()
+
+		assert.NoError(t, client.DoActions(hoverdnsapi.NewAction(hoverdnsapi.DeleteAll, "_acme-challenge.example.com", "example.com", "", 0)))
+		assert.Len(t, values(srv), 0)
+	})
+
+	t.Run("update ambiguous", func(t *testing.T) {
+		srv, client := setup(t)
+		defer srv.Close()
+
+		err := client.DoActions(hoverdnsapi.NewAction(hoverdnsapi.Update, "_acme-challenge.example.com", "example.com", "new-token", 0))
+		assert.True(t, errors.Is(err, hoverdnsapi.ErrAmbiguous), "expected ErrAmbiguous: %v", err)
+	})
+
+	t.Run("upsert append", func(t *testing.T) {
+		srv, client := setup(t)
+		defer srv.Close()
+
+		r := hoverdnsapi.Record{Name: "_acme-challenge", Type: hoverdnsapi.TypeTXT, Value: "third-token"}
+		first, err := client.UpsertRecord("example.com", r, hoverdnsapi.UpsertAppend)
+		assert.NoError(t, err)
+		again, err := client.UpsertRecord("example.com", r, hoverdnsapi.UpsertAppend)
+		if assert.NoError(t, err) {
+			assert.Equal(t, first.ID, again.ID, "repeated append added another")
+		}
+		assert.Equal(t, []string{"wildcard-token", "apex-token", "third-token"}, values(srv))
+	})
+
+	t.Run("upsert replace", func(t *testing.T) {
+		srv, client := setup(t)
+		defer srv.Close()
+
+		e, err := client.UpsertRecord("example.com", hoverdnsapi.Record{Name: "_acme-challenge.example.com", Type: hoverdnsapi.TypeTXT, Value: "only-token"}, hoverdnsapi.UpsertReplace)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "only-token", e.Content)
+		}
+		assert.Equal(t, []string{"only-token"}, values(srv))
+
+		// already the only value: nothing to change
+		before := len(srv.Requests())
+		_, err = client.UpsertRecord("example.com", hoverdnsapi.Record{Name: "_acme-challenge", Type: hoverdnsapi.TypeTXT, Value: "only-token"}, hoverdnsapi.UpsertReplace)
+		assert.NoError(t, err)
+		for _, r := range srv.Requests()[before:] {
+			assert.Contains(t, r, "GET ", "no-op upsert made a change")
+		}
+	})
+}
+
+// TestRecordTTL checks that the TTL is sent, validated, and reported back
+func TestRecordTTL(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+
+	created, err := client.CreateRecord("example.com", hoverdnsapi.Record{Name: "failover", Type: hoverdnsapi.TypeA, Value: "192.0.2.20", TTL: 300})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 300, created.TTL)
+	}
+
+	plain, err := client.CreateRecord("example.com", hoverdnsapi.Record{Name: "plain", Type: hoverdnsapi.TypeA, Value: "192.0.2.21"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, hovertest.DefaultTTL, plain.TTL, "no TTL should leave Hover's default")
+	}
+
+	updated, err := client.UpdateRecord("example.com", created.ID, hoverdnsapi.Record{Value: "192.0.2.22", TTL: 3600})
+	if assert.NoError(t, err) {
+		assert.Equal(t, 3600, updated.TTL)
+	}
+
+	// only the TTL differs: an upsert still fixes it
+	upserted, err := client.UpsertRecord("example.com", hoverdnsapi.Record{Name: "failover", Type: hoverdnsapi.TypeA, Value: "192.0.2.22", TTL: 300}, hoverdnsapi.UpsertReplace)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 300, upserted.TTL)
+	}
+	e, _ := findEntry(srv.Entries("example.com"), "failover", hoverdnsapi.TypeA)
+	assert.Equal(t, 300, e.TTL)
+
+	before := len(srv.Requests())
+	_, err = client.CreateRecord("example.com", hoverdnsapi.Record{Name: "odd", Type: hoverdnsapi.TypeA, Value: "192.0.2.23", TTL: 120})
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrUnactionable), "expected ErrUnactionable: %v", err)
+	for _, r := range srv.Requests()[before:] {
+		assert.Contains(t, r, "GET ", "invalid TTL was sent")
+	}
+
+	// and by DoActions
+	assert.NoError(t, client.DoActions(hoverdnsapi.NewAction(hoverdnsapi.Add, "acme.example.com", "example.com", "token", 86400)))
+	e, _ = findEntry(srv.Entries("example.com"), "acme", hoverdnsapi.TypeTXT)
+	assert.Equal(t, 86400, e.TTL)
+	err = client.DoActions(hoverdnsapi.NewAction(hoverdnsapi.Update, "acme.example.com", "example.com", "token2", 60))
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrUnactionable), "expected ErrUnactionable: %v", err)
+
+	assert.True(t, hoverdnsapi.ValidTTL(0))
+	assert.True(t, hoverdnsapi.ValidTTL(900))
+	assert.False(t, hoverdnsapi.ValidTTL(60))
+}
+
+// TestUpsert checks that Upsert finds an existing TXT record instead of piling up duplicates
+func TestUpsert(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+
+	existing, err := client.ExistingTXTRecords("_acme-challenge.example.com")
+	if assert.NoError(t, err) {
+		assert.Empty(t, existing)
+	}
+
+	assert.NoError(t, client.Upsert("_acme-challenge.example.com", "example.com", "one", 300))
+	assert.NoError(t, client.Upsert("_acme-challenge.example.com", "example.com", "two", 300))
+
+	existing, err = client.ExistingTXTRecords("_acme-challenge.example.com")
+	if assert.NoError(t, err) && assert.Len(t, existing, 1) {
+		assert.Equal(t, "two", existing[0].Content)
+	}
+
+	// the same value again changes nothing
+	before := len(srv.Requests())
+	assert.NoError(t, client.Upsert("_acme-challenge.example.com.", "example.com", "two", 300))
+	for _, r := range srv.Requests()[before:] {
+		assert.Contains(t, r, "GET ", "unchanged record was written")
+	}
+
+	cnames, err := client.ExistingRecords("www.example.com", "")
+	if assert.NoError(t, err) && assert.Len(t, cnames, 1) {
+		assert.Equal(t, hoverdnsapi.TypeCNAME, cnames[0].Type)
+	}
+
+	_, err = client.ExistingTXTRecords("www.example.org")
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrDomainNotFound), "expected ErrDomainNotFound: %v", err)
+}
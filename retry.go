@@ -0,0 +1,283 @@
+package hoverdnsapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RetryPolicy describes how a Client retries a request that failed in a way that's likely to be
+// temporary: a network error, a timeout, or one of the RetryableStatus codes.  Hover's API is
+// unofficial and intermittently returns a 5xx, so retries are on by default.
+//
+// Only requests whose method is in RetryableMethods are retried blindly.  Adding a record (a
+// POST) is not idempotent, so before it's retried, the domain is re-read to check whether the
+// first attempt actually succeeded; this avoids duplicate records.
+type RetryPolicy struct {
+	MaxAttempts      int           // total attempts, including the first; 1 disables retries
+	InitialBackoff   time.Duration // wait before the first retry
+	MaxBackoff       time.Duration // cap on the wait between attempts
+	Multiplier       float64       // growth of the wait per attempt, ie 2 doubles each time
+	Jitter           float64       // randomization of each wait, as a fraction: 0.2 is +/-20%
+	RetryableStatus  []int         // HTTP statuses worth retrying
+	RetryableMethods []string      // HTTP methods safe to repeat without checking first
+}
+
+var (
+	// DefaultRetryPolicy is used by a Client unless WithRetryPolicy says otherwise
+	DefaultRetryPolicy = RetryPolicy{
+		MaxAttempts:      4,
+		InitialBackoff:   500 * time.Millisecond,
+		MaxBackoff:       10 * time.Second,
+		Multiplier:       2,
+		Jitter:           0.2,
+		RetryableStatus:  []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		RetryableMethods: []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete},
+	}
+
+	// NoRetryPolicy makes each request single-shot
+	NoRetryPolicy = RetryPolicy{MaxAttempts: 1}
+)
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// validate checks that the policy makes sense
+func (p RetryPolicy) validate() error {
+	switch {
+	case p.MaxAttempts < 1:
+		return fmt.Errorf("hover: retry policy needs at least 1 attempt, not %d", p.MaxAttempts)
+	case p.InitialBackoff < 0 || p.MaxBackoff < 0:
+		return errors.New("hover: retry policy backoff can't be negative")
+	case p.Multiplier != 0 && p.Multiplier < 1:
+		return fmt.Errorf("hover: retry policy multiplier %f would shrink the backoff", p.Multiplier)
+	case p.Jitter < 0 || p.Jitter > 1:
+		return fmt.Errorf("hover: retry policy jitter %f should be within 0..1", p.Jitter)
+	}
+	return nil
+}
+
+// Backoff is the wait before retrying after the given (1-based) attempt has failed
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 1
+	}
+
+	wait := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		jitterMu.Lock()
+		wait *= 1 - p.Jitter + 2*p.Jitter*jitterRand.Float64()
+		jitterMu.Unlock()
+	}
+
+	return time.Duration(wait)
+}
+
+// retryableMethod reports whether a request of the given method can be repeated blindly
+func (p RetryPolicy) retryableMethod(method string) bool {
+	for _, m := range p.RetryableMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryable reports whether the outcome of an attempt is worth retrying: a network error other
+// than the caller giving up, or one of the retryable statuses
+func (p RetryPolicy) retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil
+	}
+	for _, status := range p.RetryableStatus {
+		if resp.StatusCode == status {
+			return true
+		}
+	}
+	return false
+}
+
+// retryableErr is retryable for an error already returned from do() and readResponse()
+func (p RetryPolicy) retryableErr(ctx context.Context, err error) bool {
+	if errors.Is(err, ErrOffline) {
+		return false
+	}
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return p.retryable(ctx, &http.Response{StatusCode: he.StatusCode}, nil)
+	}
+	return p.retryable(ctx, nil, err)
+}
+
+// WithRetryPolicy sets how requests are retried; NoRetryPolicy disables retries
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) error {
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		c.retry = policy
+		return nil
+	}
+}
+
+// apiCall is the parts of a request, kept so that the request can be rebuilt for each attempt
+type apiCall struct {
+	method     string
+	url        string
+	values     url.Values // form-encoded body, if any
+	idempotent bool       // safe to repeat even though the method normally isn't (ie login)
+	noReauth   bool       // don't log in again if the session is rejected (ie login itself)
+}
+
+// do sends the call, retrying as per the client's RetryPolicy.  If Hover rejects the session,
+// such as after it expired, the client logs in again once and the call is replayed.  As with
+// http.Client.Do, the caller closes the body of the response returned.
+func (c *Client) do(ctx context.Context, call apiCall) (*http.Response, error) {
+	if c.offline {
+		return nil, fmt.Errorf("hover: %s %s: %w", call.method, call.url, ErrOffline)
+	}
+	sent := c.currentAuth()
+	resp, err := c.attempt(ctx, call)
+	if err != nil || call.noReauth || !sessionRejected(resp) {
+		return resp, err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	c.log.Printf("hover: session rejected by %s %s (%s); logging in again", call.method, call.url, resp.Status)
+	if err := c.reauth(ctx, sent); err != nil {
+		return nil, err
+	}
+
+	call.noReauth = true
+	return c.attempt(ctx, call)
+}
+
+// attempt sends the call, retrying as per the client's RetryPolicy
+func (c *Client) attempt(ctx context.Context, call apiCall) (*http.Response, error) {
+	policy := c.retry
+	if policy.MaxAttempts < 1 {
+		policy = NoRetryPolicy
+	}
+	repeatable := call.idempotent || policy.retryableMethod(call.method)
+
+	for attempt := 1; ; attempt++ {
+		if c.limiter != nil {
+			waited, err := c.limiter.wait(ctx)
+			c.count(func(s *Stats) { s.RateLimitWait += waited })
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		req, err := c.newRequest(ctx, call.method, call.url, call.values)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.HTTPClient.Do(req)
+		c.count(func(s *Stats) {
+			s.Requests++
+			if attempt > 1 {
+				s.Retries++
+			}
+		})
+
+		// Hover asking us to back off applies to every request, not just a retry of this one
+		var hold time.Duration
+		if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+			if resp.StatusCode == http.StatusTooManyRequests {
+				c.count(func(s *Stats) { s.Throttled++ })
+			}
+			if hold = retryAfter(resp, time.Now()); hold > 0 && c.limiter != nil {
+				c.limiter.pause(time.Now().Add(hold))
+			}
+		}
+
+		if !repeatable || attempt >= policy.MaxAttempts || !policy.retryable(ctx, resp, err) {
+			return resp, err
+		}
+
+		reason := fmt.Sprintf("%v", err)
+		if resp != nil {
+			reason = resp.Status
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		wait := c.backoff(policy, attempt, hold)
+		c.log.Printf("hover: retrying %s %s in %s (attempt %d of %d failed: %s)", call.method, call.url, wait, attempt, policy.MaxAttempts, reason)
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// backoff is how long to wait before retrying the attempt: the policy's backoff, or as long as
+// Hover asked us to hold off, whichever is longer
+func (c *Client) backoff(policy RetryPolicy, attempt int, hold time.Duration) time.Duration {
+	wait := policy.Backoff(attempt)
+	if hold > wait {
+		wait = hold
+		c.count(func(s *Stats) { s.RateLimitWait += hold })
+	}
+	return wait
+}
+
+// signinPath is the path of Hover's sign-in page, where the website sends a session it no longer
+// accepts
+const signinPath = "/signin"
+
+// sessionRejected reports whether the response shows that the session isn't (or is no longer)
+// accepted: a 401 or 403, or a redirect to the sign-in page
+func sessionRejected(resp *http.Response) bool {
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return true
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		location, err := resp.Location()
+		return err == nil && signinPage(resp.Request, location)
+	case resp.Request != nil && resp.Request.Response != nil:
+		// a redirect was followed, so this may be the sign-in page itself
+		return signinPage(resp.Request.Response.Request, resp.Request.URL)
+	}
+	return false
+}
+
+// signinPage reports whether the URL, reached from the request, is Hover's sign-in page: on the
+// same host, and at exactly signinPath, so that an API path that merely mentions "signin" or
+// "login" (such as a domain's name) isn't mistaken for it
+func signinPage(from *http.Request, u *url.URL) bool {
+	if u == nil || !strings.EqualFold(strings.TrimSuffix(u.Path, "/"), signinPath) {
+		return false
+	}
+	return from == nil || from.URL == nil || strings.EqualFold(from.URL.Host, u.Host)
+}
+
+// sleep waits for the duration, or until the context is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,287 @@
+package hoverdnsapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Session is a login to Hover saved for reuse: the auth cookie, and when it expires
+type Session struct {
+	Username string    `json:"username"`
+	Cookie   string    `json:"cookie"`            // value of the hoverauth cookie
+	Expires  time.Time `json:"expires,omitempty"` // zero if Hover didn't say
+}
+
+// Expired reports whether the session has passed its expiry; a session of unknown expiry isn't
+// expired, but needs to be checked with Hover before use.
+func (s Session) Expired(now time.Time) bool {
+	return !s.Expires.IsZero() && !now.Before(s.Expires)
+}
+
+// SessionStore saves and restores logins so that each process (or each Client) doesn't need to
+// log in again; see FileSessionStore.  A caller can offer their own store, such as a secret
+// manager, as long as it's safe for concurrent use.
+type SessionStore interface {
+	// Load returns the saved session of the user; ok is false if there is none
+	Load(username string) (session Session, ok bool, err error)
+	// Save keeps the session, replacing any previous session of the same user
+	Save(session Session) error
+	// Delete forgets the session of the user, such as when Hover has rejected it
+	Delete(username string) error
+}
+
+// FileSessionStore keeps sessions in a JSON file readable only by its owner (0600), since a
+// session cookie is as good as a password until it expires.
+type FileSessionStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewFileSessionStore creates a store of sessions in the given file, which is created on the
+// first Save
+func NewFileSessionStore(path string) *FileSessionStore {
+	return &FileSessionStore{Path: path}
+}
+
+// read loads all sessions in the file, keyed by username; call with the lock held
+func (f *FileSessionStore) read() (map[string]Session, error) {
+	sessions := make(map[string]Session)
+
+	data, err := ioutil.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return sessions, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("hover: reading sessions: %w", err)
+	}
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return nil, fmt.Errorf("hover: parsing sessions in %s: %w", f.Path, err)
+	}
+	return sessions, nil
+}
+
+// write replaces the file with the given sessions; call with the lock held.  The file is written
+// aside and renamed so that a crash doesn't leave it truncated.
+func (f *FileSessionStore) write(sessions map[string]Session) error {
+	data, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(f.Path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("hover: saving sessions: %w", err)
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(f.Path)+".*") // created 0600
+	if err != nil {
+		return fmt.Errorf("hover: saving sessions: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("hover: saving sessions: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("hover: saving sessions: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return fmt.Errorf("hover: saving sessions: %w", err)
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
+
+// Load returns the saved session of the user
+func (f *FileSessionStore) Load(username string) (Session, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sessions, err := f.read()
+	if err != nil {
+		return Session{}, false, err
+	}
+	s, ok := sessions[username]
+	return s, ok, nil
+}
+
+// Save keeps the session of the user, dropping any others that have expired
+func (f *FileSessionStore) Save(session Session) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sessions, err := f.read()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for username, s := range sessions {
+		if s.Expired(now) {
+			delete(sessions, username)
+		}
+	}
+	sessions[session.Username] = session
+
+	return f.write(sessions)
+}
+
+// Delete forgets the session of the user
+func (f *FileSessionStore) Delete(username string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sessions, err := f.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := sessions[username]; !ok {
+		return nil
+	}
+	delete(sessions, username)
+
+	return f.write(sessions)
+}
+
+// WithSessionStore saves each login to the store, and tries a saved session before logging in
+func WithSessionStore(store SessionStore) Option {
+	return func(c *Client) error {
+		if store == nil {
+			return errors.New("hover: WithSessionStore: nil store")
+		}
+		c.sessions = store
+		return nil
+	}
+}
+
+// restoreSession puts a saved session into the cookie jar if Hover still accepts it; ok is false
+// if there's no usable session, meaning a login is needed.
+func (c *Client) restoreSession(ctx context.Context) (auth string, ok bool, err error) {
+	if c.sessions == nil || c.HTTPClient.Jar == nil {
+		return "", false, nil
+	}
+
+	session, ok, err := c.sessions.Load(c.Username)
+	if err != nil {
+		c.log.Printf("hover: ignoring saved sessions: %v", err)
+		return "", false, nil
+	}
+	if !ok || session.Cookie == "" {
+		return "", false, nil
+	}
+	if session.Expired(time.Now()) {
+		c.log.Printf("hover: saved session of user=%s expired at %s", c.Username, session.Expires)
+		c.forgetSession()
+		return "", false, nil
+	}
+
+	c.setAuthCookie(session.Cookie, session.Expires)
+
+	// A cheap request to check that Hover still honours the session
+	resp, err := c.do(ctx, apiCall{method: http.MethodGet, url: c.APIURL("domains"), noReauth: true})
+	if err != nil {
+		c.setAuthCookie("", time.Time{})
+		return "", false, err
+	}
+	defer resp.Body.Close()
+
+	// an expired session may be redirected to the login page, which is a 200 once followed
+	if err := checkStatus(resp); sessionRejected(resp) || errors.Is(err, ErrAuthFailed) {
+		c.setAuthCookie("", time.Time{})
+		c.log.Printf("hover: saved session of user=%s was rejected; logging in", c.Username)
+		c.forgetSession()
+		return "", false, nil
+	} else if err != nil {
+		c.setAuthCookie("", time.Time{})
+		return "", false, err
+	}
+
+	c.log.Printf("hover: reusing saved session of user=%s", c.Username)
+	return session.Cookie, true, nil
+}
+
+// saveSession keeps the auth cookie set by a successful login response, if there's a store
+func (c *Client) saveSession(resp *http.Response, auth string) {
+	if c.sessions == nil {
+		return
+	}
+
+	session := Session{Username: c.Username, Cookie: auth}
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name != authHeader {
+			continue
+		}
+		if cookie.MaxAge > 0 {
+			session.Expires = time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+		} else if !cookie.Expires.IsZero() {
+			session.Expires = cookie.Expires
+		}
+	}
+
+	if err := c.sessions.Save(session); err != nil {
+		c.log.Printf("hover: unable to save session of user=%s: %v", c.Username, err)
+	}
+}
+
+// forgetSession deletes the saved session, if there's a store
+func (c *Client) forgetSession() {
+	if c.sessions == nil {
+		return
+	}
+	if err := c.sessions.Delete(c.Username); err != nil {
+		c.log.Printf("hover: unable to forget session of user=%s: %v", c.Username, err)
+	}
+}
+
+// invalidateAuth forgets the session everywhere it's kept, so that the next request logs in
+func (c *Client) invalidateAuth() {
+	c.mu.Lock()
+	c.authCookie = ""
+	c.mu.Unlock()
+	if c.HTTPClient.Jar != nil {
+		c.setAuthCookie("", time.Time{})
+	}
+	c.forgetSession()
+}
+
+// reauth logs in again after the session that was sent was rejected.  When several requests
+// are rejected at once, only one logs in, and a session replaced since the request was sent
+// isn't discarded.
+func (c *Client) reauth(ctx context.Context, sent string) error {
+	_, err := c.flights.do("reauth", func() (interface{}, error) {
+		if current := c.currentAuth(); current != "" && current != sent {
+			return nil, nil
+		}
+		c.invalidateAuth()
+		return c.GetAuthContext(ctx)
+	})
+	return err
+}
+
+// currentAuth is the auth cookie in the jar, if any; unlike GetCookie, it's quiet
+func (c *Client) currentAuth() string {
+	if c.HTTPClient == nil || c.HTTPClient.Jar == nil {
+		return ""
+	}
+	for _, cookie := range c.HTTPClient.Jar.Cookies(c.baseURL()) {
+		if cookie.Name == authHeader {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// setAuthCookie puts the auth cookie into the jar; an empty value removes it
+func (c *Client) setAuthCookie(value string, expires time.Time) {
+	cookie := &http.Cookie{Name: authHeader, Value: value, Path: "/", Expires: expires}
+	if value == "" {
+		cookie.MaxAge = -1
+	}
+	c.HTTPClient.Jar.SetCookies(c.baseURL(), []*http.Cookie{cookie})
+}
@@ -0,0 +1,116 @@
+package hoverdnsapi_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chickenandpork/hoverdnsapi" // to ensure testing without extra access
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFileSessionStore checks saving, loading, and deleting sessions, and the file's permissions
+func TestFileSessionStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hoverdnsapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := hoverdnsapi.NewFileSessionStore(filepath.Join(dir, "sub", "sessions.json"))
+
+	_, ok, err := store.Load("scott")
+	assert.NoError(t, err)
+	assert.False(t, ok, "empty store has a session")
+
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	assert.NoError(t, store.Save(hoverdnsapi.Session{Username: "scott", Cookie: "abc123", Expires: expires}))
+	assert.NoError(t, store.Save(hoverdnsapi.Session{Username: "larry", Cookie: "def456"}))
+
+	info, err := os.Stat(store.Path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	s, ok, err := store.Load("scott")
+	if assert.NoError(t, err) && assert.True(t, ok) {
+		assert.Equal(t, "abc123", s.Cookie)
+		assert.True(t, expires.Equal(s.Expires))
+		assert.False(t, s.Expired(time.Now()))
+		assert.True(t, s.Expired(expires))
+	}
+
+	assert.NoError(t, store.Delete("scott"))
+	_, ok, _ = store.Load("scott")
+	assert.False(t, ok, "deleted session still loaded")
+	_, ok, _ = store.Load("larry")
+	assert.True(t, ok, "other session lost on delete")
+}
+
+// TestSessionReuse checks that a saved session spares a login, and that a stale one is replaced
+func TestSessionReuse(t *testing.T) {
+	srv, _ := newTestClient(t)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "hoverdnsapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := hoverdnsapi.NewFileSessionStore(filepath.Join(dir, "sessions.json"))
+
+	newClient := func() *hoverdnsapi.Client {
+		client, err := hoverdnsapi.New(
+			hoverdnsapi.WithCredentials("scott", "tiger"),
+			hoverdnsapi.WithBaseURL(srv.BaseURL()),
+			hoverdnsapi.WithLogger(&hoverdnsapi.NopLogger{}),
+			hoverdnsapi.WithRetryPolicy(fastRetry),
+			hoverdnsapi.WithSessionStore(store),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	assert.NoError(t, newClient().FillDomains())
+	assert.Equal(t, 1, countRequests(srv, "POST /api/login"))
+	first, ok, _ := store.Load("scott")
+	assert.True(t, ok, "session not saved after login")
+
+	// a new process, in effect
+	assert.NoError(t, newClient().FillDomains())
+	assert.Equal(t, 1, countRequests(srv, "POST /api/login"), "saved session not reused")
+
+	// Hover forgets the session: the probe fails, and a fresh login is saved
+	srv.ExpireSessions()
+	assert.NoError(t, newClient().FillDomains())
+	assert.Equal(t, 2, countRequests(srv, "POST /api/login"))
+	second, ok, _ := store.Load("scott")
+	if assert.True(t, ok) {
+		assert.NotEqual(t, first.Cookie, second.Cookie)
+	}
+
+	// Hover forgets the session, and redirects to its login page rather than refusing: the probe
+	// is followed to a 200, but the session is still stale
+	srv.ExpireSessions()
+	srv.RedirectToLogin(true)
+	before := countRequests(srv, "GET /api/domains")
+	assert.NoError(t, newClient().FillDomains())
+	assert.Equal(t, 3, countRequests(srv, "POST /api/login"))
+	assert.Equal(t, before+2, countRequests(srv, "GET /api/domains"), "redirected session was taken as valid")
+	third, ok, _ := store.Load("scott")
+	if assert.True(t, ok) {
+		assert.NotEqual(t, second.Cookie, third.Cookie)
+	}
+	srv.RedirectToLogin(false)
+
+	// a session known to have expired isn't even tried
+	assert.NoError(t, store.Save(hoverdnsapi.Session{Username: "scott", Cookie: third.Cookie, Expires: time.Now().Add(-time.Minute)}))
+	before = countRequests(srv, "GET /api/domains")
+	assert.NoError(t, newClient().FillDomains())
+	assert.Equal(t, 4, countRequests(srv, "POST /api/login"))
+	assert.Equal(t, before+1, countRequests(srv, "GET /api/domains"), "expired session was probed")
+}
@@ -0,0 +1,50 @@
+package hoverdnsapi
+
+import (
+	"sync"
+)
+
+// flightGroup deduplicates concurrent calls of the same work, such as several goroutines sharing
+// a Client all finding that the domains need filling: the first caller does the work, and the
+// rest wait for, and share, its result.  This is a small cut of golang.org/x/sync/singleflight,
+// to avoid the dependency.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is a call in progress or completed
+type flight struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// do runs fn unless a call with the same key is already in flight, in which case it waits for
+// that call and returns its result.  Note that the work is bound to the context of the first
+// caller; if that's cancelled, the callers sharing it all see the cancellation.
+func (g *flightGroup) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+	if f, ok := g.flights[key]; ok {
+		g.mu.Unlock()
+		f.wg.Wait()
+		return f.val, f.err
+	}
+	f := &flight{}
+	f.wg.Add(1)
+	g.flights[key] = f
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.flights, key)
+		g.mu.Unlock()
+		f.wg.Done()
+	}()
+
+	f.val, f.err = fn()
+	return f.val, f.err
+}
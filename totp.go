@@ -0,0 +1,63 @@
+package hoverdnsapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPStep is the period for which each time-based code is valid
+	TOTPStep = 30 * time.Second
+
+	// totpDigits is the length of each code, as shown by authenticator apps
+	totpDigits = 6
+)
+
+// decodeTOTPSecret decodes the base32 secret shown when 2FA is set up; spaces, dashes, padding,
+// and case are ignored, since the secret is usually copied by hand.
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	cleaned := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '=':
+			return -1
+		}
+		return r
+	}, strings.ToUpper(secret))
+	if cleaned == "" {
+		return nil, fmt.Errorf("hover: empty TOTP secret")
+	}
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("hover: TOTP secret is not base32: %w", err)
+	}
+	return key, nil
+}
+
+// TOTPCode generates the RFC 6238 time-based one-time password for the secret at the given
+// time: 6 digits from HMAC-SHA1 over a 30-second step, the same as an authenticator app would
+// show.
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(TOTPStep/time.Second)))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// RFC 4226 dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, code%1000000), nil
+}
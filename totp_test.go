@@ -0,0 +1,40 @@
+package hoverdnsapi_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chickenandpork/hoverdnsapi" // to ensure testing without extra access
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTOTPCode checks codes against the SHA1 test vectors of RFC 6238, truncated to 6 digits
+func TestTOTPCode(t *testing.T) {
+	// base32 of the RFC's ASCII secret "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	for unix, expected := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		code, err := hoverdnsapi.TOTPCode(secret, time.Unix(unix, 0))
+		if assert.NoError(t, err) {
+			assert.Equal(t, expected, code, "code at %d", unix)
+		}
+	}
+
+	// as copied by hand from a setup page
+	code, err := hoverdnsapi.TOTPCode("gezd gnbv gy3t qojq gezd gnbv gy3t qojq", time.Unix(59, 0))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "287082", code)
+	}
+
+	_, err = hoverdnsapi.TOTPCode("not base32!", time.Now())
+	assert.Error(t, err)
+	_, err = hoverdnsapi.TOTPCode("", time.Now())
+	assert.Error(t, err)
+}
@@ -0,0 +1,668 @@
+package hoverdnsapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// txtChunk is the longest character-string a TXT record can hold (RFC 1035 3.3); longer values
+// are split into several strings, which resolvers join back together
+const txtChunk = 255
+
+// WriteZone writes the entries of the domain as an RFC 1035 master file (a BIND zone file).
+// Names are written relative to the $ORIGIN of the domain, and names in values (CNAME, MX, NS,
+// SRV targets) are made absolute.  The most common TTL becomes the $TTL, so only the entries that
+// differ carry their own.  Entries are sorted so that the same domain always renders the same.
+//
+// Hover doesn't show the SOA, nor its own NS records, so there are none in the file; a server
+// loading it needs an SOA added.
+func WriteZone(w io.Writer, d Domain) error {
+	entries := append([]Entry(nil), d.Entries...)
+	sortEntries(entries)
+	ttl := zoneTTL(entries)
+
+	fmt.Fprintf(w, "; %s, exported from Hover\n", d.DomainName)
+	fmt.Fprintf(w, "$ORIGIN %s.\n", strings.TrimSuffix(d.DomainName, "."))
+	fmt.Fprintf(w, "$TTL %d\n", ttl)
+
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, e := range entries {
+		var entryTTL string
+		if e.TTL > 0 && e.TTL != ttl {
+			entryTTL = strconv.Itoa(e.TTL)
+		}
+		var note string
+		if e.Default {
+			note = "\t; Hover default"
+		}
+		fmt.Fprintf(tw, "%s\t%s\tIN\t%s\t%s%s\n", zoneName(e.Name), entryTTL, strings.ToUpper(e.Type), zoneContent(e), note)
+	}
+	return tw.Flush()
+}
+
+// sortEntries puts entries in a stable order: the domain itself first, then by name, type, and
+// content
+func sortEntries(entries []Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Name != b.Name {
+			return zoneName(a.Name) == "@" || (zoneName(b.Name) != "@" && a.Name < b.Name)
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Content < b.Content
+	})
+}
+
+// ExportZone writes a domain, by name or ID, as an RFC 1035 master file; see WriteZone.  The
+// entries are read from Hover if not already cached.
+func (c *Client) ExportZone(w io.Writer, domain string) error {
+	return c.ExportZoneContext(context.Background(), w, domain)
+}
+
+// ExportZoneContext is ExportZone bound to a context for cancellation and deadlines
+func (c *Client) ExportZoneContext(ctx context.Context, w io.Writer, domain string) error {
+	d, err := c.GetDomainContext(ctx, domain)
+	if err != nil {
+		return err
+	}
+	if d.Entries, err = c.ListRecordsContext(ctx, d.DomainName, RecordFilter{}); err != nil {
+		return err
+	}
+	return WriteZone(w, d)
+}
+
+// zoneTTL picks the most common TTL of the entries, preferring the shorter on a tie; Hover's
+// default if there are none
+func zoneTTL(entries []Entry) int {
+	counts := make(map[int]int)
+	for _, e := range entries {
+		if e.TTL > 0 {
+			counts[e.TTL]++
+		}
+	}
+
+	ttl, most := hoverDefaultTTL, 0
+	for t, n := range counts {
+		if n > most || (n == most && t < ttl) {
+			ttl, most = t, n
+		}
+	}
+	return ttl
+}
+
+// zoneName is the owner name of an entry relative to the origin; Hover shows the domain itself
+// as "@", and its wildcard as "*"
+func zoneName(name string) string {
+	if name == "" {
+		return "@"
+	}
+	return name
+}
+
+// zoneContent renders the value of an entry in master-file form
+func zoneContent(e Entry) string {
+	fields := strings.Fields(e.Content)
+
+	switch strings.ToUpper(e.Type) {
+	case TypeTXT:
+		return quoteTXT(e.Content)
+	case TypeCNAME, TypeNS:
+		return absoluteName(e.Content)
+	case TypeMX:
+		if len(fields) == 2 {
+			return fields[0] + " " + absoluteName(fields[1])
+		}
+	case TypeSRV:
+		if len(fields) == 4 {
+			return strings.Join(fields[:3], " ") + " " + absoluteName(fields[3])
+		}
+	case TypeCAA:
+		if len(fields) >= 3 && !strings.HasPrefix(fields[2], `"`) {
+			return fields[0] + " " + fields[1] + " " + quoteString(strings.Join(fields[2:], " "))
+		}
+	}
+	return e.Content
+}
+
+// absoluteName makes a name in a value absolute, as Hover shows them without the trailing dot;
+// a bare label (no dots) or "@" is left relative to the origin
+func absoluteName(name string) string {
+	if name == "@" || strings.HasSuffix(name, ".") || !strings.Contains(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// quoteTXT quotes a TXT value, splitting it into 255-byte strings if need be
+func quoteTXT(value string) string {
+	if value == "" {
+		return `""`
+	}
+
+	var chunks []string
+	for len(value) > txtChunk {
+		chunks = append(chunks, quoteString(value[:txtChunk]))
+		value = value[txtChunk:]
+	}
+	return strings.Join(append(chunks, quoteString(value)), " ")
+}
+
+// quoteString quotes a character-string, escaping quotes and backslashes, and writing
+// unprintable bytes as \DDD
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// maxIncludeDepth bounds nested $INCLUDEs, so that a file including itself fails rather than
+// recursing forever
+const maxIncludeDepth = 8
+
+// ParseZone reads an RFC 1035 master file (a BIND zone file) of the domain, returning its records
+// as entries named relative to the domain, as Hover shows them.  $ORIGIN, $TTL, and $INCLUDE are
+// honoured, as are records continued over several lines by parentheses, and quoted or split TXT
+// strings.  The SOA and the NS records of the domain itself are skipped, since Hover keeps its
+// own.  A record that doesn't parse, lies outside the domain, or is of a type that Hover doesn't
+// accept is ErrZoneSyntax.
+//
+// $INCLUDE names a file relative to the working directory; see ParseZoneFile.
+func ParseZone(r io.Reader, domain string) ([]Entry, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("hover: reading zone: %w", err)
+	}
+
+	p := zoneParser{domain: Domain{DomainName: strings.TrimSuffix(domain, ".")}}
+	if err := p.parse(data, "zone", "", p.domain.DomainName+".", 0); err != nil {
+		return nil, err
+	}
+	return p.entries, nil
+}
+
+// ParseZoneFile is ParseZone reading a file; $INCLUDE names a file relative to the file that
+// includes it
+func ParseZoneFile(filename, domain string) ([]Entry, error) {
+	p := zoneParser{domain: Domain{DomainName: strings.TrimSuffix(domain, ".")}, relative: true}
+	if err := p.include(filename, "", p.domain.DomainName+".", 0); err != nil {
+		return nil, err
+	}
+	return p.entries, nil
+}
+
+// ImportZone adds entries, such as those read by ParseZone, to a domain (by name or ID) through
+// DoActions, skipping any that the domain already holds; the actions are returned.  An entry that
+// Hover would refuse, such as one with a TTL not in ValidTTLs (600 and 1800 are common in zone
+// files), is skipped rather than stopping the import: the rest are added, and the error is an
+// *ActionsError listing those skipped (ErrUnactionable) along with any that failed.  With dryRun,
+// nothing is changed at all: the actions are only returned, to show what would be done.
+func (c *Client) ImportZone(domain string, entries []Entry, dryRun bool) ([]Action, error) {
+	return c.ImportZoneContext(context.Background(), domain, entries, dryRun)
+}
+
+// ImportZoneContext is ImportZone bound to a context for cancellation and deadlines
+func (c *Client) ImportZoneContext(ctx context.Context, domain string, entries []Entry, dryRun bool) ([]Action, error) {
+	d, err := c.GetDomainContext(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	if d.Entries, err = c.ListRecordsContext(ctx, d.DomainName, RecordFilter{}); err != nil {
+		return nil, err
+	}
+
+	actions := make([]Action, 0, len(entries))
+	var skipped []ActionResult
+	for _, e := range entries {
+		fqdn := d.DomainName
+		if e.Name != "@" && e.Name != "" {
+			fqdn = e.Name + "." + d.DomainName
+		}
+		a := NewRecordAction(Add, fqdn, d.DomainName, strings.ToUpper(e.Type), e.Content, uint(e.TTL))
+		if !ValidRecordType(a.rrtype) {
+			skipped = append(skipped, ActionResult{Action: a, Err: fmt.Errorf("hover: %s: type %s isn't accepted by Hover: %w", fqdn, e.Type, ErrUnactionable)})
+			continue
+		}
+		if err := a.checkTTL(); err != nil {
+			skipped = append(skipped, ActionResult{Action: a, Err: err})
+			continue
+		}
+		if zoneHolds(d, a) {
+			continue
+		}
+		name, _ := d.hostname(fqdn)
+		d.Entries = append(d.Entries, Entry{Name: name, Type: a.rrtype, Content: a.Content()}) // a zone may repeat a record
+		actions = append(actions, a)
+	}
+
+	results := make([]ActionResult, 0, len(actions))
+	if dryRun || len(actions) == 0 {
+		for _, a := range actions {
+			results = append(results, ActionResult{Action: a})
+		}
+	} else if results, err = c.DoActionsResults(ctx, actions...); results == nil {
+		return actions, err // failed before any action was attempted
+	}
+
+	if len(skipped) == 0 {
+		return actions, err
+	}
+	return actions, &ActionsError{Results: append(skipped, results...)}
+}
+
+// zoneHolds reports whether the domain already holds the record that the action would add.  The
+// values are compared as a zone file would show them, so that a value quoted in one (such as
+// that of a CAA record) and not in the other is still the same.
+func zoneHolds(d Domain, a Action) bool {
+	name, _ := d.hostname(a.fqdn)
+	content := zoneContent(Entry{Name: name, Type: a.rrtype, Content: a.Content()})
+	for _, e := range d.FindEntries(a.fqdn, a.rrtype, "") {
+		if zoneContent(e) == content {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneParser gathers the entries of a domain from a zone file and any files it includes
+type zoneParser struct {
+	domain   Domain
+	relative bool // $INCLUDE is relative to the including file, rather than the working directory
+	entries  []Entry
+}
+
+// zoneState is what carries from one record to the next within a file
+type zoneState struct {
+	origin   string // absolute, with the trailing dot
+	ttl      int    // set by $TTL
+	lastTTL  int    // of the last record that gave one, used if there's no $TTL
+	lastName string // absolute owner of the last record, used by a record that omits it
+}
+
+// include reads and parses a zone file, from within the directory of the including file if
+// relative
+func (p *zoneParser) include(filename, dir, origin string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("hover: %s: $INCLUDE nested too deeply: %w", filename, ErrZoneSyntax)
+	}
+	if p.relative && dir != "" && !filepath.IsAbs(filename) {
+		filename = filepath.Join(dir, filename)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("hover: reading zone: %w", err)
+	}
+	return p.parse(data, filename, filepath.Dir(filename), origin, depth)
+}
+
+// parse adds the entries of a zone file already read; name is used in errors
+func (p *zoneParser) parse(data []byte, name, dir, origin string, depth int) error {
+	records, err := lexZone(data, name)
+	if err != nil {
+		return err
+	}
+
+	st := zoneState{origin: origin}
+	for _, rec := range records {
+		if err := p.record(&st, rec, dir, depth); err != nil {
+			if inc, ok := err.(includeError); ok {
+				return inc.err // already placed within the included file
+			}
+			return fmt.Errorf("hover: %s:%d: %v: %w", name, rec.line, err, ErrZoneSyntax)
+		}
+	}
+	return nil
+}
+
+// record handles a single directive or resource record
+func (p *zoneParser) record(st *zoneState, rec zoneRecord, dir string, depth int) error {
+	t := rec.tokens
+	if !rec.blankOwner && !t[0].quoted && strings.HasPrefix(t[0].text, "$") {
+		return p.directive(st, t, dir, depth)
+	}
+
+	owner := st.lastName
+	if !rec.blankOwner {
+		owner, t = absoluteZoneName(t[0].text, st.origin), t[1:]
+	}
+	if owner == "" {
+		return errors.New("record has no owner name")
+	}
+	st.lastName = owner
+
+	// the TTL and class are both optional, in either order
+	ttl, ttlSeen := 0, false
+	for len(t) > 0 && !t[0].quoted {
+		if zoneClasses[strings.ToUpper(t[0].text)] {
+			if !strings.EqualFold(t[0].text, "IN") {
+				return fmt.Errorf("class %s isn't supported", t[0].text)
+			}
+			t = t[1:]
+			continue
+		}
+		if n, ok := parseZoneTTL(t[0].text); ok && !ttlSeen {
+			ttl, ttlSeen, t = n, true, t[1:]
+			continue
+		}
+		break
+	}
+	if len(t) == 0 {
+		return errors.New("record has no type")
+	}
+	switch {
+	case ttlSeen:
+		st.lastTTL = ttl
+	case st.ttl > 0:
+		ttl = st.ttl
+	case st.lastTTL > 0:
+		ttl = st.lastTTL
+	default:
+		ttl = hoverDefaultTTL
+	}
+
+	rrtype, rdata := strings.ToUpper(t[0].text), t[1:]
+	apex := strings.EqualFold(owner, p.domain.DomainName+".")
+	if rrtype == "SOA" || (rrtype == TypeNS && apex) {
+		return nil // Hover's own
+	}
+	if !ValidRecordType(rrtype) {
+		return fmt.Errorf("type %s isn't accepted by Hover", rrtype)
+	}
+	hostname, ok := p.domain.hostname(owner)
+	if !ok {
+		return fmt.Errorf("%s is outside %s", owner, p.domain.DomainName)
+	}
+
+	content, err := zoneRdata(rrtype, rdata, st.origin)
+	if err != nil {
+		return err
+	}
+	p.entries = append(p.entries, Entry{Name: hostname, Type: rrtype, Content: content, TTL: ttl})
+	return nil
+}
+
+// includeError carries the failure of an included file out of the including file unchanged
+type includeError struct {
+	err error
+}
+
+func (e includeError) Error() string {
+	return e.err.Error()
+}
+
+// directive handles $ORIGIN, $TTL, and $INCLUDE
+func (p *zoneParser) directive(st *zoneState, t []zoneToken, dir string, depth int) error {
+	switch strings.ToUpper(t[0].text) {
+	case "$ORIGIN":
+		if len(t) != 2 {
+			return errors.New("$ORIGIN needs a name")
+		}
+		st.origin = absoluteZoneName(t[1].text, st.origin)
+	case "$TTL":
+		if len(t) != 2 {
+			return errors.New("$TTL needs a TTL")
+		}
+		ttl, ok := parseZoneTTL(t[1].text)
+		if !ok {
+			return fmt.Errorf("$TTL %s isn't a TTL", t[1].text)
+		}
+		st.ttl = ttl
+	case "$INCLUDE":
+		if len(t) < 2 || len(t) > 3 {
+			return errors.New("$INCLUDE needs a file, and optionally an origin")
+		}
+		origin := st.origin
+		if len(t) == 3 {
+			origin = absoluteZoneName(t[2].text, st.origin)
+		}
+		if err := p.include(t[1].text, dir, origin, depth+1); err != nil {
+			return includeError{err}
+		}
+	default:
+		return fmt.Errorf("unknown directive %s", t[0].text)
+	}
+	return nil
+}
+
+// zoneClasses are the classes that can appear in a record; only IN is of any use to Hover
+var zoneClasses = map[string]bool{"IN": true, "CH": true, "CS": true, "HS": true}
+
+// zoneRdata renders the data of a record as Hover holds it in Entry.Content, the reverse of
+// zoneContent
+func zoneRdata(rrtype string, rdata []zoneToken, origin string) (string, error) {
+	want := map[string]int{TypeA: 1, TypeAAAA: 1, TypeCNAME: 1, TypeNS: 1, TypeMX: 2, TypeSRV: 4, TypeCAA: 3}
+	if n, ok := want[rrtype]; ok && len(rdata) != n {
+		return "", fmt.Errorf("%s record needs %d values, not %d", rrtype, n, len(rdata))
+	}
+	if len(rdata) == 0 {
+		return "", fmt.Errorf("%s record has no value", rrtype)
+	}
+
+	// numbers leads the data of MX, SRV, and CAA
+	numbers := func(n int) error {
+		for _, tok := range rdata[:n] {
+			if _, err := strconv.ParseUint(tok.text, 10, 16); err != nil {
+				return fmt.Errorf("%s record has %q where a number is needed", rrtype, tok.text)
+			}
+		}
+		return nil
+	}
+	target := func(tok zoneToken) string {
+		return strings.TrimSuffix(absoluteZoneName(tok.text, origin), ".")
+	}
+
+	switch rrtype {
+	case TypeA, TypeAAAA:
+		ip := net.ParseIP(rdata[0].text)
+		if ip == nil || (ip.To4() != nil) != (rrtype == TypeA) {
+			return "", fmt.Errorf("%s record has %q, not an address", rrtype, rdata[0].text)
+		}
+		return rdata[0].text, nil
+	case TypeCNAME, TypeNS:
+		return target(rdata[0]), nil
+	case TypeMX:
+		if err := numbers(1); err != nil {
+			return "", err
+		}
+		return rdata[0].text + " " + target(rdata[1]), nil
+	case TypeSRV:
+		if err := numbers(3); err != nil {
+			return "", err
+		}
+		return rdata[0].text + " " + rdata[1].text + " " + rdata[2].text + " " + target(rdata[3]), nil
+	case TypeCAA:
+		if err := numbers(1); err != nil {
+			return "", err
+		}
+		return rdata[0].text + " " + rdata[1].text + " " + quoteString(rdata[2].text), nil // quoted, as zoneContent writes it
+	case TypeTXT:
+		var b strings.Builder
+		for _, tok := range rdata {
+			b.WriteString(tok.text)
+		}
+		return b.String(), nil
+	}
+	return "", fmt.Errorf("type %s isn't supported", rrtype)
+}
+
+// absoluteZoneName makes a name absolute (with the trailing dot) against the origin; "@" is the
+// origin itself.  Names are folded to lower case.
+func absoluteZoneName(name, origin string) string {
+	name = strings.ToLower(name)
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return name
+	}
+	return name + "." + origin
+}
+
+// parseZoneTTL parses a TTL in seconds, or in BIND's units such as "1h30m", "2d", or "1w"
+func parseZoneTTL(s string) (int, bool) {
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return 0, false
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, true
+	}
+
+	units := map[byte]int{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+	total, n := 0, -1
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= '0' && c <= '9' {
+			if n < 0 {
+				n = 0
+			}
+			n = n*10 + int(c-'0')
+			continue
+		}
+		unit, ok := units[c|0x20] // lower case
+		if !ok || n < 0 {
+			return 0, false
+		}
+		total, n = total+n*unit, -1
+	}
+	if n >= 0 {
+		return 0, false // a number without a unit after others with
+	}
+	return total, true
+}
+
+// zoneToken is a single field of a record; quoted fields are never directives, classes, or TTLs
+type zoneToken struct {
+	text   string
+	quoted bool
+}
+
+// zoneRecord is a record (or directive) as fields, after joining lines within parentheses and
+// removing comments
+type zoneRecord struct {
+	line       int  // where the record starts, for errors
+	blankOwner bool // started with a space, so the owner is that of the previous record
+	tokens     []zoneToken
+}
+
+// lexZone splits a zone file into records of fields
+func lexZone(data []byte, name string) ([]zoneRecord, error) {
+	var (
+		records []zoneRecord
+		cur     zoneRecord
+		parens  int
+		line    = 1
+		atStart = true // at the start of a line that isn't within parentheses
+	)
+	add := func(tok zoneToken) {
+		if len(cur.tokens) == 0 {
+			cur.line = line
+		}
+		cur.tokens = append(cur.tokens, tok)
+	}
+
+	for i := 0; i < len(data); {
+		c := data[i]
+		if atStart && (c == ' ' || c == '\t') {
+			cur.blankOwner = true
+		}
+		atStart = false
+
+		switch {
+		case c == '\n':
+			line++
+			i++
+			if parens == 0 {
+				if len(cur.tokens) > 0 {
+					records = append(records, cur)
+				}
+				cur, atStart = zoneRecord{}, true
+			}
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == ';':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == '(':
+			parens++
+			i++
+		case c == ')':
+			if parens == 0 {
+				return nil, fmt.Errorf("hover: %s:%d: unbalanced ')': %w", name, line, ErrZoneSyntax)
+			}
+			parens--
+			i++
+		case c == '"':
+			start := line
+			var b []byte
+			for i++; i < len(data) && data[i] != '"'; i++ {
+				switch {
+				case data[i] == '\n':
+					line++
+				case data[i] == '\\' && i+3 < len(data) && isDigits(data[i+1:i+4]):
+					n, _ := strconv.Atoi(string(data[i+1 : i+4]))
+					b = append(b, byte(n))
+					i += 3
+					continue
+				case data[i] == '\\' && i+1 < len(data):
+					i++
+				}
+				b = append(b, data[i])
+			}
+			if i >= len(data) {
+				return nil, fmt.Errorf("hover: %s:%d: unterminated quote: %w", name, start, ErrZoneSyntax)
+			}
+			i++
+			add(zoneToken{text: string(b), quoted: true})
+		default:
+			start := i
+			for i < len(data) && !strings.ContainsRune(" \t\r\n;()\"", rune(data[i])) {
+				if data[i] == '\\' && i+1 < len(data) {
+					i++
+				}
+				i++
+			}
+			add(zoneToken{text: string(data[start:i])})
+		}
+	}
+	if parens > 0 {
+		return nil, fmt.Errorf("hover: %s:%d: unbalanced '(': %w", name, line, ErrZoneSyntax)
+	}
+	if len(cur.tokens) > 0 {
+		records = append(records, cur)
+	}
+	return records, nil
+}
+
+// isDigits reports whether every byte is a decimal digit
+func isDigits(b []byte) bool {
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
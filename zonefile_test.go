@@ -0,0 +1,217 @@
+package hoverdnsapi_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chickenandpork/hoverdnsapi" // to ensure testing without extra access
+	"github.com/chickenandpork/hoverdnsapi/hovertest"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWriteZone checks the rendering of each record type, names, TTLs, and long TXT values
+func TestWriteZone(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	d := hoverdnsapi.Domain{DomainName: "example.com", Entries: []hoverdnsapi.Entry{
+		{Name: "www", Type: "CNAME", Content: "example.com", TTL: 900},
+		{Name: "@", Type: "A", Content: "192.0.2.1", TTL: 900, Default: true},
+		{Name: "*", Type: "A", Content: "192.0.2.1", TTL: 900, Default: true},
+		{Name: "@", Type: "MX", Content: "10 mx.hover.com.cust.hostedemail.com", TTL: 900},
+		{Name: "_sip._tcp", Type: "SRV", Content: "10 5 5060 sip.example.com", TTL: 3600},
+		{Name: "@", Type: "CAA", Content: "0 issue letsencrypt.org", TTL: 900},
+		{Name: "note", Type: "TXT", Content: `say "hi" \o/`, TTL: 300},
+		{Name: "dkim", Type: "TXT", Content: long, TTL: 900},
+		{Name: "v6", Type: "AAAA", Content: "2001:db8::1", TTL: 900},
+	}}
+
+	var out bytes.Buffer
+	if !assert.NoError(t, hoverdnsapi.WriteZone(&out, d)) {
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	fields := make([][]string, 0, len(lines))
+	for _, l := range lines {
+		fields = append(fields, strings.Fields(l))
+	}
+
+	assert.Equal(t, "$ORIGIN example.com.", lines[1])
+	assert.Equal(t, "$TTL 900", lines[2])
+	assert.Equal(t, []string{"@", "IN", "A", "192.0.2.1", ";", "Hover", "default"}, fields[3])
+	assert.Equal(t, []string{"@", "IN", "CAA", "0", "issue", `"letsencrypt.org"`}, fields[4])
+	assert.Equal(t, []string{"@", "IN", "MX", "10", "mx.hover.com.cust.hostedemail.com."}, fields[5])
+	assert.Equal(t, []string{"*", "IN", "A", "192.0.2.1", ";", "Hover", "default"}, fields[6])
+	assert.Equal(t, []string{"_sip._tcp", "3600", "IN", "SRV", "10", "5", "5060", "sip.example.com."}, fields[7])
+	assert.Equal(t, []string{"dkim", "IN", "TXT", `"` + long[:255] + `"`, `"` + long[255:] + `"`}, fields[8])
+	assert.Equal(t, `note 300 IN TXT "say \"hi\" \\o/"`, strings.Join(fields[9], " "))
+	assert.Equal(t, []string{"v6", "IN", "AAAA", "2001:db8::1"}, fields[10])
+	assert.Equal(t, []string{"www", "IN", "CNAME", "example.com."}, fields[11])
+
+	// the same domain always renders the same
+	var again bytes.Buffer
+	d.Entries[0], d.Entries[8] = d.Entries[8], d.Entries[0]
+	assert.NoError(t, hoverdnsapi.WriteZone(&again, d))
+	assert.Equal(t, out.String(), again.String())
+}
+
+// TestExportZone checks that a domain's entries are read from Hover for export
+func TestExportZone(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+
+	var out bytes.Buffer
+	if assert.NoError(t, client.ExportZone(&out, "example.com")) {
+		assert.Contains(t, out.String(), "$ORIGIN example.com.\n")
+		assert.Regexp(t, `(?m)^www +IN +CNAME +example\.com\.$`, out.String())
+	}
+}
+
+// TestParseZone checks directives, continuation lines, quoting, and the records skipped
+func TestParseZone(t *testing.T) {
+	zone := `; migrated from elsewhere
+$ORIGIN example.com.
+$TTL 1h
+@	IN	SOA	ns1.example.net. hostmaster.example.com. (
+		2024010101 ; serial
+		3600 900 604800 300 )
+	IN	NS	ns1.example.net.
+	IN	A	192.0.2.1
+www	300	IN	CNAME	@
+mail	IN	900	MX	10 mx1
+	MX	20 mx2.example.net.
+_sip._tcp	SRV	10 5 5060 sip
+"txt"	TXT	( "v=spf1 include:_spf.example.net"
+		  " ~all" )
+quoted	TXT	"say \"hi\"\059 ok"
+@	CAA	0 issue "letsencrypt.org"
+$ORIGIN lab.example.com.
+host	AAAA	2001:db8::1
+`
+	entries, err := hoverdnsapi.ParseZone(strings.NewReader(zone), "example.com")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []hoverdnsapi.Entry{
+		{Name: "@", Type: "A", Content: "192.0.2.1", TTL: 3600},
+		{Name: "www", Type: "CNAME", Content: "example.com", TTL: 300},
+		{Name: "mail", Type: "MX", Content: "10 mx1.example.com", TTL: 900},
+		{Name: "mail", Type: "MX", Content: "20 mx2.example.net", TTL: 3600},
+		{Name: "_sip._tcp", Type: "SRV", Content: "10 5 5060 sip.example.com", TTL: 3600},
+		{Name: "txt", Type: "TXT", Content: "v=spf1 include:_spf.example.net ~all", TTL: 3600},
+		{Name: "quoted", Type: "TXT", Content: `say "hi"; ok`, TTL: 3600},
+		{Name: "@", Type: "CAA", Content: `0 issue "letsencrypt.org"`, TTL: 3600},
+		{Name: "host.lab", Type: "AAAA", Content: "2001:db8::1", TTL: 3600},
+	}, entries)
+
+	for _, bad := range []string{
+		"www IN A 192.0.2.300\n",
+		"www IN PTR host.example.com.\n",
+		"www.example.org. IN A 192.0.2.1\n",
+		"www IN A ( 192.0.2.1\n",
+		`www IN TXT "open` + "\n",
+		"$INCLUDE\n",
+		"www IN MX mx.example.com.\n",
+	} {
+		_, err := hoverdnsapi.ParseZone(strings.NewReader(bad), "example.com")
+		assert.True(t, errors.Is(err, hoverdnsapi.ErrZoneSyntax), "expected ErrZoneSyntax for %q: %v", bad, err)
+	}
+}
+
+// TestParseZoneFile checks $INCLUDE, relative to the including file, and a round trip from export
+func TestParseZoneFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zone")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	d := hoverdnsapi.Domain{DomainName: "example.com", Entries: []hoverdnsapi.Entry{
+		{Name: "@", Type: "CAA", Content: `0 issue "letsencrypt.org"`, TTL: 900},
+		{Name: "@", Type: "MX", Content: "10 mx.example.net", TTL: 900},
+		{Name: "*", Type: "A", Content: "192.0.2.1", TTL: 900},
+		{Name: "dkim", Type: "TXT", Content: strings.Repeat("k", 400), TTL: 3600},
+	}}
+	var exported bytes.Buffer
+	assert.NoError(t, hoverdnsapi.WriteZone(&exported, d))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "exported.zone"), exported.Bytes(), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "lab.zone"), []byte("* A 192.0.2.3\n"), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.zone"), []byte("$INCLUDE exported.zone\nsub 300 IN A 192.0.2.2\n$INCLUDE lab.zone lab.example.com.\n"), 0600))
+
+	entries, err := hoverdnsapi.ParseZoneFile(filepath.Join(dir, "main.zone"), "example.com")
+	if assert.NoError(t, err) && assert.Len(t, entries, 6) {
+		assert.Equal(t, d.Entries, entries[:4])
+		assert.Equal(t, hoverdnsapi.Entry{Name: "sub", Type: "A", Content: "192.0.2.2", TTL: 300}, entries[4])
+		assert.Equal(t, hoverdnsapi.Entry{Name: "*.lab", Type: "A", Content: "192.0.2.3", TTL: hovertest.DefaultTTL}, entries[5], "origin given by $INCLUDE")
+	}
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "loop.zone"), []byte("$INCLUDE loop.zone\n"), 0600))
+	_, err = hoverdnsapi.ParseZoneFile(filepath.Join(dir, "loop.zone"), "example.com")
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrZoneSyntax), "expected ErrZoneSyntax: %v", err)
+}
+
+// TestImportZone checks that an import adds only what's missing, and a dry run nothing at all
+func TestImportZone(t *testing.T) {
+	srv, client := newTestClient(t)
+	defer srv.Close()
+
+	entries, err := hoverdnsapi.ParseZone(strings.NewReader(`
+@	900	A	192.0.2.1
+www	900	CNAME	example.com.
+ftp	3600	A	192.0.2.5
+ftp	3600	A	192.0.2.5
+_dmarc	300	TXT	"v=DMARC1; p=none"
+@	900	CAA	0 issue "letsencrypt.org"
+`), "example.com")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	before := len(srv.Entries("example.com"))
+	actions, err := client.ImportZone("example.com", entries, true)
+	if assert.NoError(t, err) {
+		assert.Len(t, actions, 3, "existing and repeated records are skipped")
+		assert.Len(t, srv.Entries("example.com"), before, "dry run changed the domain")
+	}
+
+	_, err = client.ImportZone("example.com", entries, false)
+	assert.NoError(t, err)
+	e, ok := findEntry(srv.Entries("example.com"), "_dmarc", hoverdnsapi.TypeTXT)
+	if assert.True(t, ok) {
+		assert.Equal(t, "v=DMARC1; p=none", e.Content)
+		assert.Equal(t, 300, e.TTL)
+	}
+
+	actions, err = client.ImportZone("example.com", entries, false)
+	if assert.NoError(t, err) {
+		assert.Empty(t, actions, "a second import adds nothing")
+	}
+
+	// a CAA record that Hover shows unquoted is the same record
+	_, err = client.CreateRecord("example.com", hoverdnsapi.Record{Name: "@", Type: hoverdnsapi.TypeCAA, Value: "0 issue pki.goog"})
+	assert.NoError(t, err)
+	entries = append(entries, hoverdnsapi.Entry{Name: "@", Type: "CAA", Content: `0 issue "pki.goog"`, TTL: 900})
+	actions, err = client.ImportZone("example.com", entries, true)
+	if assert.NoError(t, err) {
+		assert.Empty(t, actions, "unquoted CAA not recognized")
+	}
+
+	// a TTL that Hover won't take is skipped and reported, and the rest imported
+	entries = append(entries, hoverdnsapi.Entry{Name: "odd", Type: "A", Content: "192.0.2.9", TTL: 600})
+	entries = append([]hoverdnsapi.Entry{{Name: "early", Type: "A", Content: "192.0.2.8", TTL: 300}}, entries...)
+	actions, err = client.ImportZone("example.com", entries, false)
+	assert.Len(t, actions, 1)
+	assert.True(t, errors.Is(err, hoverdnsapi.ErrUnactionable), "expected ErrUnactionable: %v", err)
+	var ae *hoverdnsapi.ActionsError
+	if assert.True(t, errors.As(err, &ae)) && assert.Len(t, ae.Failed(), 1) {
+		assert.Equal(t, "odd.example.com", ae.Failed()[0].Action.FQDN())
+	}
+	_, ok = findEntry(srv.Entries("example.com"), "early", hoverdnsapi.TypeA)
+	assert.True(t, ok, "import stopped by a record skipped")
+	_, ok = findEntry(srv.Entries("example.com"), "odd", hoverdnsapi.TypeA)
+	assert.False(t, ok)
+}